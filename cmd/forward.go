@@ -3,13 +3,20 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
 	"github.com/takescoop/kubectl-exec-forward/internal/execforward"
 	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+	"github.com/takescoop/kubectl-exec-forward/internal/tracing"
+	"github.com/takescoop/kubectl-exec-forward/internal/trust"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
@@ -18,75 +25,367 @@ func newForwardCommand(streams genericclioptions.IOStreams, version string) *cob
 	configFlags := genericclioptions.NewConfigFlags(false)
 
 	cmd := &cobra.Command{
-		Use:     "kubectl exec-forward TYPE/NAME PORT [options] -- [command...]",
-		Short:   "Port forward to Kubernetes resources and execute commands found in annotations",
-		Args:    cobra.MinimumNArgs(2),
+		Use:   "kubectl exec-forward TYPE/NAME PORT [options] -- [command...]",
+		Short: "Port forward to Kubernetes resources and execute commands found in annotations",
+		Example: "  kubectl exec-forward svc/db 5432 -- psql\n" +
+			"  kubectl exec-forward --target svc/db:postgres --target cache=svc/redis:6379 -- ./migrate.sh",
+		Args: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			if !flags.Changed("target") && !flags.Changed("filename") {
+				return cobra.MinimumNArgs(2)(cmd, args)
+			}
+
+			if n := len(positionalArgs(cmd, args)); n != 0 && n != 2 {
+				return fmt.Errorf("accepts a resource and a port before --, or none along with --target or --filename, received %d argument(s)", n)
+			}
+
+			return nil
+		},
 		Version: version,
+		// Errors are printed by Execute, which knows whether a failing command has already reported them.
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := cmd.Context()
+			// Usage is only relevant to errors parsing the command line, which occur before RunE is called.
+			cmd.SilenceUsage = true
+
 			flags := cmd.Flags()
 
-			podTimeout, err := flags.GetDuration("pod-timeout")
+			ephemeral, err := parseEphemeralFlags(cmd)
 			if err != nil {
 				return err
 			}
 
-			client := forwarder.NewClient(podTimeout, streams)
-			if err := client.Init(configFlags, version); err != nil {
+			targets, annotations, err := parseTargetFlags(cmd)
+			if err != nil {
 				return err
 			}
 
-			sigChan := make(chan os.Signal, 1)
-			signal.Notify(sigChan, os.Interrupt)
+			positional := positionalArgs(cmd, args)
+			if !flags.Changed("target") && !flags.Changed("filename") {
+				// Without targets, the resource and port are the first arguments, even when the command does not follow --.
+				positional = args[:2]
+			}
 
-			cmdArgs, err := parseArgFlag(cmd)
-			if err != nil {
-				return err
+			if len(positional) == 2 {
+				targets = append([]execforward.Target{{Resource: positional[0], Port: positional[1]}}, targets...)
+			}
+
+			if len(targets) == 0 {
+				return fmt.Errorf("no target to forward to")
 			}
 
 			config := &execforward.Config{
-				Command: args[2:],
+				Command:     args[len(positional):],
+				Ephemeral:   ephemeral,
+				Name:        targets[0].Name,
+				Targets:     targets[1:],
+				Annotations: annotations,
 			}
 
-			v, err := flags.GetBool("verbose")
-			if err != nil {
+			if config.Wake, err = flags.GetBool("wake"); err != nil {
 				return err
 			}
 
-			config.Verbose = v
-
-			p, err := flags.GetBool("persist")
-			if err != nil {
+			if config.WakeTimeout, err = flags.GetDuration("wake-timeout"); err != nil {
 				return err
 			}
 
-			config.Persist = p
-
-			cancelCtx, cancel := context.WithCancel(ctx)
-
-			go func() {
-				<-sigChan
+			if config.SpawnTimeout, err = flags.GetDuration("spawn-timeout"); err != nil {
+				return err
+			}
 
-				cancel()
-			}()
+			if config.JobTTL, err = flags.GetDuration("job-ttl"); err != nil {
+				return err
+			}
 
-			return execforward.Run(cancelCtx, client, config, cmdArgs, args[0], args[1], streams)
+			return runSession(cmd, configFlags, config, targets[0].Resource, targets[0].Port, streams, version)
 		},
 	}
 
 	flags := cmd.Flags()
 
+	addSessionFlags(flags)
+
+	flags.StringArray("target", []string{}, "Target forwarded to as [name=]TYPE/NAME:PORT, exposed to commands as .Targets.<name>.LocalPort (repeatable, commands are read from the first target)")
+	flags.StringP("filename", "f", "", "Session spec file listing the targets forwarded to, and commands overriding those found in annotations")
+
+	flags.String("ephemeral-image", "", "Image of an ephemeral container added to the pod, for pods lacking the tooling to reach the port")
+	flags.String("ephemeral-relay", "", "Address the ephemeral container relays the forwarded port to with socat, e.g., 127.0.0.1:5432")
+	flags.Duration("ephemeral-timeout", time.Minute, "Time to wait for the ephemeral container to start")
+	flags.Bool("wake", false, "Scale deployments and statefulsets without replicas up before forwarding, and back down on exit")
+	flags.Duration("wake-timeout", 5*time.Minute, "Time to wait for a replica of a woken up workload to be ready")
+	flags.Duration("spawn-timeout", forwarder.DefaultSpawnTimeout, "Time to wait for the pod of a job spawned from a cronjob to be ready")
+	flags.Duration("job-ttl", forwarder.DefaultJobTTL, "Time a job spawned from a cronjob may run, should it not be deleted on exit")
+
+	configFlags.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(
+		newProxyCommand(configFlags, streams, version),
+		newRelayCommand(configFlags, streams, version),
+		newWakeCommand(configFlags, streams, version),
+	)
+
+	return cmd
+}
+
+// addSessionFlags adds the flags configuring forwarding sessions and their commands.
+func addSessionFlags(flags *pflag.FlagSet) {
 	flags.StringArrayP("arg", "a", []string{}, "key=value arguments to be passed to commands")
 	flags.BoolP("verbose", "v", false, "Whether to write command outputs to console")
-	flags.DurationP("pod-timeout", "t", 500, "Time to wait for an attachable pod to become available")
+	flags.DurationP("pod-timeout", "t", 500*time.Millisecond, "Time to wait for an attachable pod to become available")
 	flags.BoolP("persist", "p", false, "Whether to persist the connection after the main command has finished")
+	flags.StringP("container", "c", "", "Container used to resolve named ports and container-scoped annotations")
+	flags.String("trust-policy", trust.DefaultPath(), "Path to the trust policy file listing commands allowed to run without confirmation")
+	flags.BoolP("yes", "y", false, "Run annotation commands without asking for confirmation")
+	flags.Bool("no-tty", false, "Run the main command detached from the terminal, for scripts and CI jobs (alias --capture)")
+	flags.String("stdin", "", "File piped to the main command's input, or - for the plugin's input (implies --no-tty)")
+	flags.String("output", "", "File the main command's output is written to (implies --no-tty)")
+	flags.Bool("no-cache", false, "Run commands instead of reusing their cached outputs")
+	flags.Bool("require-signed", false, "Refuse to run commands from annotations without a valid signature")
+	flags.StringArray("public-key", []string{}, "Path to a PEM encoded public key used to verify annotation signatures")
+	flags.Duration("grace-period", 10*time.Second, "Time commands are given to exit on shutdown before being killed, also bounding teardown commands and the rest of the shutdown")
+	flags.Duration("idle-timeout", 0, "Close the session once no traffic has gone through the forwarded ports for this long, e.g., 30m (disabled when 0)")
+	flags.Duration("max-duration", 0, "Close the session once it has been connected for this long, e.g., 8h (disabled when 0)")
+	flags.String("transport", string(forwarder.TransportSPDY), "Port-forwarding transport, one of websocket, spdy or auto to fall back to spdy when websocket upgrades fail")
+	flags.String("trace-endpoint", "", "URL of an OTLP/HTTP collector traces of the session are exported to, e.g., http://localhost:4318")
+	flags.String("trace-file", "", "File traces of the session are written to as JSON")
+	flags.String("metrics-addr", "", "Address traffic metrics of the forwarded ports are served on in Prometheus text format, under /metrics, e.g., 127.0.0.1:9090")
+
+	flags.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "capture" {
+			name = "no-tty"
+		}
 
-	configFlags.AddFlags(cmd.PersistentFlags())
+		return pflag.NormalizedName(name)
+	})
+}
 
-	return cmd
+// tracingShutdownTimeout bounds exporting pending spans once the session has ended.
+const tracingShutdownTimeout = 5 * time.Second
+
+// runSession completes config from the command's session flags, then forwards to the resource and runs its commands
+// until the session ends or is interrupted.
+func runSession(cmd *cobra.Command, configFlags *genericclioptions.ConfigFlags, config *execforward.Config, resource string, port string, streams genericclioptions.IOStreams, version string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+
+	podTimeout, err := flags.GetDuration("pod-timeout")
+	if err != nil {
+		return err
+	}
+
+	transportName, err := flags.GetString("transport")
+	if err != nil {
+		return err
+	}
+
+	transport, err := forwarder.ParseTransport(transportName)
+	if err != nil {
+		return err
+	}
+
+	traceEndpoint, err := flags.GetString("trace-endpoint")
+	if err != nil {
+		return err
+	}
+
+	traceFile, err := flags.GetString("trace-file")
+	if err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{Endpoint: traceEndpoint, File: traceFile, Version: version})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			fmt.Fprintf(streams.ErrOut, "Unable to export traces: %v\n", err)
+		}
+	}()
+
+	newClient := func(getter genericclioptions.RESTClientGetter) (*forwarder.Client, error) {
+		client := forwarder.NewClient(podTimeout, streams)
+		client.Transport = transport
+
+		if err := client.Init(getter, version); err != nil {
+			return nil, err
+		}
+
+		return client, nil
+	}
+
+	client, err := newClient(configFlags)
+	if err != nil {
+		return err
+	}
+
+	config.ClientFor = func(context string, namespace string) (*forwarder.Client, error) {
+		return newClient(targetConfigFlags(configFlags, context, namespace))
+	}
+
+	cmdArgs, err := parseArgFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	v, err := flags.GetBool("verbose")
+	if err != nil {
+		return err
+	}
+
+	config.Verbose = v
+
+	p, err := flags.GetBool("persist")
+	if err != nil {
+		return err
+	}
+
+	config.Persist = p
+
+	container, err := flags.GetString("container")
+	if err != nil {
+		return err
+	}
+
+	config.Container = container
+
+	trustPath, err := flags.GetString("trust-policy")
+	if err != nil {
+		return err
+	}
+
+	policy, err := trust.Load(trustPath)
+	if err != nil {
+		return err
+	}
+
+	requireSigned, err := flags.GetBool("require-signed")
+	if err != nil {
+		return err
+	}
+
+	publicKeys, err := flags.GetStringArray("public-key")
+	if err != nil {
+		return err
+	}
+
+	policy.Override(requireSigned, publicKeys)
+
+	config.Trust = policy
+
+	yes, err := flags.GetBool("yes")
+	if err != nil {
+		return err
+	}
+
+	config.AssumeYes = yes
+
+	noCache, err := flags.GetBool("no-cache")
+	if err != nil {
+		return err
+	}
+
+	if !noCache {
+		config.Cache = command.NewFileCache(command.DefaultCacheDir())
+	}
+
+	gracePeriod, err := flags.GetDuration("grace-period")
+	if err != nil {
+		return err
+	}
+
+	config.GracePeriod = gracePeriod
+
+	idleTimeout, err := flags.GetDuration("idle-timeout")
+	if err != nil {
+		return err
+	}
+
+	config.IdleTimeout = idleTimeout
+
+	maxDuration, err := flags.GetDuration("max-duration")
+	if err != nil {
+		return err
+	}
+
+	config.MaxDuration = maxDuration
+
+	metricsAddr, err := flags.GetString("metrics-addr")
+	if err != nil {
+		return err
+	}
+
+	config.MetricsAddr = metricsAddr
+
+	config.Interrupts = command.NewInterrupts()
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	defer signal.Stop(sigChan)
+
+	go handleSignals(sigChan, config.Interrupts, cancel, streams)
+
+	closeStdio, err := parseStdioFlags(cmd, config, streams)
+	if err != nil {
+		return err
+	}
+
+	defer closeStdio()
+
+	return execforward.Run(cancelCtx, client, config, cmdArgs, resource, port, streams)
+}
+
+// targetConfigFlags returns the configuration flags of targets found in another context or namespace. Flags selecting
+// the cluster and user are only kept when the target's context is the session's, while the kubeconfig files,
+// impersonation and timeout always apply. The session's namespace only applies to targets in the session's context.
+func targetConfigFlags(configFlags *genericclioptions.ConfigFlags, context string, namespace string) *genericclioptions.ConfigFlags {
+	f := genericclioptions.NewConfigFlags(false)
+
+	f.KubeConfig = configFlags.KubeConfig
+	f.Impersonate = configFlags.Impersonate
+	f.ImpersonateUID = configFlags.ImpersonateUID
+	f.ImpersonateGroup = configFlags.ImpersonateGroup
+	f.Timeout = configFlags.Timeout
+
+	if context == "" {
+		f.Context = configFlags.Context
+		f.Namespace = configFlags.Namespace
+		f.ClusterName = configFlags.ClusterName
+		f.AuthInfoName = configFlags.AuthInfoName
+		f.APIServer = configFlags.APIServer
+		f.TLSServerName = configFlags.TLSServerName
+		f.Insecure = configFlags.Insecure
+		f.CertFile = configFlags.CertFile
+		f.KeyFile = configFlags.KeyFile
+		f.CAFile = configFlags.CAFile
+		f.BearerToken = configFlags.BearerToken
+		f.Username = configFlags.Username
+		f.Password = configFlags.Password
+	} else {
+		f.Context = &context
+	}
+
+	if namespace != "" {
+		f.Namespace = &namespace
+	}
+
+	return f
 }
 
-// Execute executes the forward command.
+// pluginFailurePrefix starts the last line printed to stderr when the plugin itself fails, followed by its exit code.
+const pluginFailurePrefix = "exec-forward: plugin failure, exit code"
+
+// Execute executes the forward command and exits with the main command's exit code, or with one of the
+// execforward.ExitCode constants when the plugin itself fails.
 func Execute(version string) {
 	cmd := newForwardCommand(genericclioptions.IOStreams{
 		Out:    os.Stdout,
@@ -94,7 +393,211 @@ func Execute(version string) {
 		In:     os.Stdin,
 	}, version)
 
-	cobra.CheckErr(cmd.Execute())
+	err := cmd.Execute()
+	reportError(os.Stderr, err)
+
+	os.Exit(execforward.ExitCode(err))
+}
+
+// reportError prints the error unless the failed command already reported it. Failures of the plugin itself end with
+// a line carrying the exit code, so that wrapping scripts can tell them apart from the main command exiting with the
+// same code.
+func reportError(w io.Writer, err error) {
+	if err == nil {
+		return
+	}
+
+	if !execforward.Reported(err) {
+		fmt.Fprintln(w, "Error:", err)
+	}
+
+	if execforward.PluginFailure(err) {
+		fmt.Fprintf(w, "%s %d\n", pluginFailurePrefix, execforward.ExitCode(err))
+	}
+}
+
+// handleSignals shuts the session down gracefully on the first interrupt, termination or hangup signal, and exits
+// immediately on the next one.
+func handleSignals(sigChan <-chan os.Signal, interrupts *command.Interrupts, cancel func(), streams genericclioptions.IOStreams) {
+	for sig := range sigChan {
+		// Interrupts received while an interactive command runs are meant for the command, e.g., to cancel a query.
+		if sig == os.Interrupt && interrupts.Handle(sig) {
+			continue
+		}
+
+		break
+	}
+
+	fmt.Fprintln(streams.ErrOut, "Shutting down, interrupt again to force quit")
+	cancel()
+
+	sig := <-sigChan
+
+	fmt.Fprintln(streams.ErrOut, "Forced quit")
+	os.Exit(signalExitCode(sig))
+}
+
+// signalExitCode returns the exit code of a process killed by the passed signal, as in a shell.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+
+	return execforward.ExitCodeError
+}
+
+// parseTargetFlags returns the targets listed in the --filename session spec, followed by the --target targets, along
+// with the annotations overridden by the commands the session spec declares.
+func parseTargetFlags(cmd *cobra.Command) ([]execforward.Target, map[string]string, error) {
+	flags := cmd.Flags()
+	targets := []execforward.Target{}
+	annotations := map[string]string{}
+
+	filename, err := flags.GetString("filename")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if filename != "" {
+		spec, err := execforward.LoadSpec(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		targets = append(targets, spec.Targets...)
+
+		if annotations, err = spec.Annotations(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	raw, err := flags.GetStringArray("target")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range raw {
+		t, err := execforward.ParseTarget(r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets, annotations, nil
+}
+
+// parseEphemeralFlags returns the ephemeral container configured by the --ephemeral-* flags, or nil when no image is
+// set.
+func parseEphemeralFlags(cmd *cobra.Command) (*forwarder.EphemeralContainer, error) {
+	flags := cmd.Flags()
+
+	image, err := flags.GetString("ephemeral-image")
+	if err != nil {
+		return nil, err
+	}
+
+	relay, err := flags.GetString("ephemeral-relay")
+	if err != nil {
+		return nil, err
+	}
+
+	if image == "" {
+		if relay != "" {
+			return nil, fmt.Errorf("--ephemeral-relay requires --ephemeral-image")
+		}
+
+		return nil, nil
+	}
+
+	timeout, err := flags.GetDuration("ephemeral-timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	return &forwarder.EphemeralContainer{Image: image, Relay: relay, Timeout: timeout}, nil
+}
+
+// parseStdioFlags configures the main command's input and output from the --no-tty, --stdin and --output flags. The
+// output file is only created when the main command starts, so that sessions failing earlier leave it untouched. The
+// returned function closes any file opened for the main command.
+func parseStdioFlags(cmd *cobra.Command, config *execforward.Config, streams genericclioptions.IOStreams) (func(), error) {
+	flags := cmd.Flags()
+	closers := []io.Closer{}
+
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+
+	noTTY, err := flags.GetBool("no-tty")
+	if err != nil {
+		return closeAll, err
+	}
+
+	stdin, err := flags.GetString("stdin")
+	if err != nil {
+		return closeAll, err
+	}
+
+	output, err := flags.GetString("output")
+	if err != nil {
+		return closeAll, err
+	}
+
+	config.NoTTY = noTTY || stdin != "" || output != ""
+
+	switch stdin {
+	case "":
+	case "-":
+		config.Stdin = streams.In
+	default:
+		f, err := os.Open(stdin)
+		if err != nil {
+			return closeAll, err
+		}
+
+		closers = append(closers, f)
+		config.Stdin = f
+	}
+
+	if output != "" {
+		f := &outputFile{path: output}
+
+		closers = append(closers, f)
+		config.OpenStdout = f.open
+	}
+
+	return closeAll, nil
+}
+
+// outputFile is the main command's output file, created when it is opened.
+type outputFile struct {
+	path string
+	f    *os.File
+}
+
+// open creates or truncates the file.
+func (o *outputFile) open() (io.Writer, error) {
+	f, err := os.Create(o.path)
+	if err != nil {
+		return nil, err
+	}
+
+	o.f = f
+
+	return f, nil
+}
+
+// Close closes the file, if it was opened.
+func (o *outputFile) Close() error {
+	if o.f == nil {
+		return nil
+	}
+
+	return o.f.Close()
 }
 
 // parseArgFlag parses the passed command line --args into a key value map.
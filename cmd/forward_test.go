@@ -3,9 +3,12 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -14,7 +17,11 @@ import (
 	"github.com/howeyc/fsnotify"
 	"github.com/phayes/freeport"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"github.com/takescoop/kubectl-exec-forward/internal/execforward"
+	"github.com/takescoop/kubectl-exec-forward/internal/kubetest"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -47,6 +54,277 @@ func TestParseArgs(t *testing.T) {
 	})
 }
 
+func TestReportError(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		err error
+
+		expected string
+	}{
+		{name: "success", err: nil, expected: ""},
+		{
+			name:     "plugin failure",
+			err:      &execforward.Error{Code: execforward.ExitCodeConfig, Err: errors.New("invalid annotation")},
+			expected: "Error: invalid annotation\nexec-forward: plugin failure, exit code 251\n",
+		},
+		{
+			name:     "reported hook failure",
+			err:      &execforward.Error{Code: execforward.ExitCodeHook, Err: &command.FakeExitError{Code: 1}},
+			expected: "exec-forward: plugin failure, exit code 253\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+
+			reportError(&out, tc.err)
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestForwardCommandArgs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		args []string
+
+		error bool
+	}{
+		{name: "resource and port", args: []string{"svc/db", "5432"}},
+		{name: "main command without separator", args: []string{"svc/db", "5432", "psql"}},
+		{name: "targets", args: []string{"--target", "svc/db:5432", "--target", "svc/redis:6379", "--", "./migrate.sh"}},
+		{name: "resource along with targets", args: []string{"--target", "svc/redis:6379", "svc/db", "5432", "--", "./migrate.sh"}},
+		{name: "session spec", args: []string{"-f", "session.yaml"}},
+		{name: "missing port", args: []string{"svc/db"}, error: true},
+		{name: "main command without separator along with targets", args: []string{"--target", "svc/db:5432", "psql"}, error: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+			require.NoError(t, cmd.ParseFlags(tc.args))
+
+			err := cmd.ValidateArgs(cmd.Flags().Args())
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestForwardCommandSpecWithoutTargets(t *testing.T) {
+	t.Parallel()
+
+	spec := filepath.Join(t.TempDir(), "session.yaml")
+	require.NoError(t, os.WriteFile(spec, []byte("args:\n  username: admin\n"), 0o600))
+
+	cases := []struct {
+		name string
+		args []string
+	}{
+		{name: "without command", args: []string{"-f", spec}},
+		{name: "with command", args: []string{"-f", spec, "--", "psql"}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+			cmd.SetArgs(tc.args)
+
+			assert.EqualError(t, cmd.Execute(), "no target to forward to")
+		})
+	}
+}
+
+func TestTargetConfigFlags(t *testing.T) {
+	t.Parallel()
+
+	sessionContext, sessionNamespace, server := "primary", "app", "https://primary.example.com"
+
+	configFlags := genericclioptions.NewConfigFlags(false)
+	configFlags.Context = &sessionContext
+	configFlags.Namespace = &sessionNamespace
+	configFlags.APIServer = &server
+
+	cases := []struct {
+		name string
+
+		context   string
+		namespace string
+
+		expectedContext   string
+		expectedNamespace string
+		expectedServer    string
+	}{
+		{
+			name:              "namespace in the session's context",
+			namespace:         "db",
+			expectedContext:   "primary",
+			expectedNamespace: "db",
+			expectedServer:    "https://primary.example.com",
+		},
+		{
+			name:              "another context",
+			context:           "replica",
+			expectedContext:   "replica",
+			expectedNamespace: "",
+			expectedServer:    "",
+		},
+		{
+			name:              "namespace in another context",
+			context:           "replica",
+			namespace:         "db",
+			expectedContext:   "replica",
+			expectedNamespace: "db",
+			expectedServer:    "",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := targetConfigFlags(configFlags, tc.context, tc.namespace)
+
+			assert.Equal(t, tc.expectedContext, *f.Context)
+			assert.Equal(t, tc.expectedNamespace, *f.Namespace)
+			assert.Equal(t, tc.expectedServer, *f.APIServer)
+		})
+	}
+}
+
+func TestParseStdioFlags(t *testing.T) {
+	t.Run("attach the main command to the terminal by default", func(t *testing.T) {
+		cmd := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+		assert.NoError(t, cmd.ParseFlags([]string{}))
+
+		config := &execforward.Config{}
+
+		closeStdio, err := parseStdioFlags(cmd, config, genericclioptions.NewTestIOStreamsDiscard())
+		assert.NoError(t, err)
+		closeStdio()
+
+		assert.Equal(t, &execforward.Config{}, config)
+	})
+
+	t.Run("accept --capture as an alias of --no-tty", func(t *testing.T) {
+		cmd := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+		assert.NoError(t, cmd.ParseFlags([]string{"--capture"}))
+
+		config := &execforward.Config{}
+
+		closeStdio, err := parseStdioFlags(cmd, config, genericclioptions.NewTestIOStreamsDiscard())
+		assert.NoError(t, err)
+		closeStdio()
+
+		assert.True(t, config.NoTTY)
+		assert.Nil(t, config.Stdin)
+	})
+
+	t.Run("read input from the plugin's input", func(t *testing.T) {
+		streams, in, _, _ := genericclioptions.NewTestIOStreams()
+
+		cmd := newForwardCommand(streams, "0.0.0")
+		assert.NoError(t, cmd.ParseFlags([]string{"--stdin", "-"}))
+
+		config := &execforward.Config{}
+
+		closeStdio, err := parseStdioFlags(cmd, config, streams)
+		assert.NoError(t, err)
+		closeStdio()
+
+		assert.True(t, config.NoTTY)
+		assert.Equal(t, in, config.Stdin)
+	})
+
+	t.Run("read input from and write output to files", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.sql")
+		output := filepath.Join(dir, "output.csv")
+
+		assert.NoError(t, os.WriteFile(input, []byte("select 1;"), 0o600))
+
+		cmd := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+		assert.NoError(t, cmd.ParseFlags([]string{"--stdin", input, "--output", output}))
+
+		config := &execforward.Config{}
+
+		closeStdio, err := parseStdioFlags(cmd, config, genericclioptions.NewTestIOStreamsDiscard())
+		assert.NoError(t, err)
+
+		b, err := io.ReadAll(config.Stdin)
+		assert.NoError(t, err)
+		assert.Equal(t, "select 1;", string(b))
+
+		_, err = os.Stat(output)
+		assert.True(t, os.IsNotExist(err), "output file created before the main command started")
+
+		stdout, err := config.OpenStdout()
+		assert.NoError(t, err)
+
+		_, err = stdout.Write([]byte("1"))
+		assert.NoError(t, err)
+
+		closeStdio()
+
+		b, err = os.ReadFile(output)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", string(b))
+	})
+
+	t.Run("leave the output file untouched when the main command does not start", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "output.csv")
+
+		assert.NoError(t, os.WriteFile(output, []byte("previous export"), 0o600))
+
+		cmd := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+		assert.NoError(t, cmd.ParseFlags([]string{"--output", output}))
+
+		closeStdio, err := parseStdioFlags(cmd, &execforward.Config{}, genericclioptions.NewTestIOStreamsDiscard())
+		assert.NoError(t, err)
+		closeStdio()
+
+		b, err := os.ReadFile(output)
+		assert.NoError(t, err)
+		assert.Equal(t, "previous export", string(b))
+	})
+
+	t.Run("error on a missing input file", func(t *testing.T) {
+		cmd := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+		assert.NoError(t, cmd.ParseFlags([]string{"--stdin", filepath.Join(t.TempDir(), "missing")}))
+
+		closeStdio, err := parseStdioFlags(cmd, &execforward.Config{}, genericclioptions.NewTestIOStreamsDiscard())
+		assert.Error(t, err)
+		closeStdio()
+	})
+}
+
 type SafeBuffer struct {
 	mutex sync.RWMutex
 	buf   bytes.Buffer
@@ -126,6 +404,7 @@ func TestRunForwardCommand(t *testing.T) {
 		pod.Namespace,
 		"--verbose",
 		"--persist",
+		"--yes",
 		fmt.Sprintf("pod/%s", pod.Name),
 		fmt.Sprintf("%d:%d", localPort, pod.Spec.Containers[0].Ports[0].ContainerPort),
 	})
@@ -185,6 +464,56 @@ func TestRunForwardCommand(t *testing.T) {
 	assert.True(t, strings.HasPrefix(outErr.String(), ""), "stderr was not empty")
 }
 
+func TestRunForwardCommandOffline(t *testing.T) {
+	server := kubetest.NewServer(t)
+
+	dir := t.TempDir()
+	doneFile := filepath.Join(dir, "done")
+
+	server.Add(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+			Annotations: map[string]string{
+				annotation.PreConnect: `[{"command": ["echo", "test"]}]`,
+				annotation.Command:    fmt.Sprintf(`{"command": ["touch", %q]}`, doneFile),
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "nginx",
+					Ports: []corev1.ContainerPort{{ContainerPort: 80}},
+				},
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	out := &SafeBuffer{}
+
+	cmd := newForwardCommand(genericclioptions.IOStreams{
+		Out:    out,
+		ErrOut: io.Discard,
+	}, "0.0.0")
+
+	cmd.SetArgs([]string{
+		"--kubeconfig",
+		server.WriteKubeconfig(t, "test"),
+		"--verbose",
+		"--yes",
+		"--no-cache",
+		"--trust-policy",
+		filepath.Join(dir, "trust.yaml"),
+		"pod/test",
+		"0:80",
+	})
+
+	assert.NoError(t, cmd.ExecuteContext(context.Background()))
+	assert.FileExists(t, doneFile)
+	assert.True(t, strings.HasPrefix(out.String(), "test"), "stdout did not contain hook command output")
+}
+
 func waitForFinish(t *testing.T, doneChan chan bool, errChan chan error) {
 	t.Helper()
 
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/takescoop/kubectl-exec-forward/internal/execforward"
+	"github.com/takescoop/kubectl-exec-forward/internal/proxy"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// defaultRelayPort is the port relay pods serve SOCKS5 on, when none is passed.
+const defaultRelayPort = "1080"
+
+// newProxyCommand returns the command serving a local proxy to in-cluster services through a relay pod.
+func newProxyCommand(configFlags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams, version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "proxy TYPE/NAME [PORT] [options] -- [command...]",
+		Short: "Serve a local SOCKS5 proxy to in-cluster services through a relay pod and execute commands found in annotations",
+		Long: "Forward to a relay pod serving SOCKS5, by default on port " + defaultRelayPort + ", and serve a local SOCKS5 " +
+			"proxy, and optionally an HTTP proxy, whose connections are dialed by the relay. Cluster DNS names such as " +
+			"db.prod.svc.cluster.local can then be reached through a single forwarding connection.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if n := len(positionalArgs(cmd, args)); n < 1 || n > 2 {
+				return fmt.Errorf("accepts a resource and an optional port, received %d argument(s)", n)
+			}
+
+			return nil
+		},
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			positional := positionalArgs(cmd, args)
+
+			port := "0:" + defaultRelayPort
+			if len(positional) == 2 {
+				port = "0:" + positional[1]
+			}
+
+			flags := cmd.Flags()
+
+			listen, err := flags.GetString("listen")
+			if err != nil {
+				return err
+			}
+
+			httpListen, err := flags.GetString("http-listen")
+			if err != nil {
+				return err
+			}
+
+			config := &execforward.Config{
+				Command: args[len(positional):],
+				Proxy:   &proxy.Config{SOCKSAddr: listen, HTTPAddr: httpListen},
+			}
+
+			return runSession(cmd, configFlags, config, positional[0], port, streams, version)
+		},
+	}
+
+	flags := cmd.Flags()
+
+	addSessionFlags(flags)
+
+	flags.String("listen", "127.0.0.1:1080", "Address the local SOCKS5 proxy listens on")
+	flags.String("http-listen", "", "Address a local HTTP proxy, supporting CONNECT tunnels, listens on (disabled when empty)")
+
+	return cmd
+}
+
+// positionalArgs returns the arguments passed before the -- separating the main command.
+func positionalArgs(cmd *cobra.Command, args []string) []string {
+	if n := cmd.ArgsLenAtDash(); n >= 0 {
+		return args[:n]
+	}
+
+	return args
+}
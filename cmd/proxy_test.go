@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestProxyCommandArgs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		args []string
+
+		error bool
+	}{
+		{name: "resource", args: []string{"proxy", "svc/gateway"}},
+		{name: "resource and port", args: []string{"proxy", "svc/gateway", "1081"}},
+		{name: "main command", args: []string{"proxy", "svc/gateway", "--", "curl", "http://db.db.svc"}},
+		{name: "missing resource", args: []string{"proxy"}, error: true},
+		{name: "too many arguments", args: []string{"proxy", "svc/gateway", "1081", "1082"}, error: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := newForwardCommand(genericclioptions.NewTestIOStreamsDiscard(), "0.0.0")
+
+			cmd, args, err := root.Find(tc.args)
+			require.NoError(t, err)
+			require.Equal(t, "proxy", cmd.Name())
+			require.NoError(t, cmd.ParseFlags(args))
+
+			err = cmd.ValidateArgs(cmd.Flags().Args())
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/takescoop/kubectl-exec-forward/internal/attachablepod"
+	"github.com/takescoop/kubectl-exec-forward/internal/execforward"
+	"github.com/takescoop/kubectl-exec-forward/internal/relay"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+)
+
+// relayDeleteTimeout bounds the deletion of relay pods once a session has ended.
+const relayDeleteTimeout = 30 * time.Second
+
+// newRelayCommand returns the command forwarding to an external endpoint through a short-lived relay pod.
+func newRelayCommand(configFlags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams, version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "relay --to HOST:PORT [options] -- [command...]",
+		Short: "Port forward to an external endpoint through a short-lived relay pod and execute commands found in annotations",
+		Long: "Create a relay pod forwarding connections to an endpoint only reachable from the cluster, such as a managed " +
+			"database, wait for it to start running and forward to it. The relay pod is deleted once the session ends.",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			options, err := parseRelayFlags(cmd, configFlags)
+			if err != nil {
+				return err
+			}
+
+			port, err := options.Port()
+			if err != nil {
+				return err
+			}
+
+			flags := cmd.Flags()
+
+			localPort, err := flags.GetInt("local-port")
+			if err != nil {
+				return err
+			}
+
+			readyTimeout, err := flags.GetDuration("ready-timeout")
+			if err != nil {
+				return err
+			}
+
+			clientset, err := newClientset(configFlags)
+			if err != nil {
+				return err
+			}
+
+			client := relay.New(clientset)
+
+			// Signals are not yet handled by the session, but must not leave the relay pod behind.
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+			pod, err := client.Create(ctx, options)
+			if err != nil {
+				stop()
+
+				return &execforward.Error{Code: execforward.ExitCodeConfig, Err: err}
+			}
+
+			fmt.Fprintf(streams.ErrOut, "Created relay pod %s/%s to %s\n", pod.Namespace, pod.Name, options.To)
+
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), relayDeleteTimeout)
+				defer cancel()
+
+				if err := client.Delete(ctx, pod); err != nil {
+					fmt.Fprintf(streams.ErrOut, "Unable to delete relay pod, remove it with `kubectl exec-forward relay gc`: %v\n", err)
+
+					return
+				}
+
+				fmt.Fprintf(streams.ErrOut, "Deleted relay pod %s/%s\n", pod.Namespace, pod.Name)
+			}()
+
+			// The relay pod is waited for like forwarded pods are, reporting its progress and failing as soon as it
+			// cannot start, e.g., because its image cannot be pulled.
+			pods := attachablepod.New(configFlags)
+			pods.Progress = streams.ErrOut
+
+			_, _, err = pods.GetContext(ctx, "pod/"+pod.Name, pod.Namespace, readyTimeout)
+
+			stop()
+
+			if err != nil {
+				return &execforward.Error{Code: execforward.ExitCodeTunnel, Err: err}
+			}
+
+			config := &execforward.Config{
+				Command: args,
+			}
+
+			return runSession(cmd, configFlags, config, "pod/"+pod.Name, fmt.Sprintf("%d:%d", localPort, port), streams, version)
+		},
+	}
+
+	flags := cmd.Flags()
+
+	addSessionFlags(flags)
+
+	flags.String("to", "", "Address connections are relayed to, in host:port format")
+	flags.String("image", relay.DefaultImage, "Image of the relay pod, which must provide socat")
+	flags.String("service-account", "", "Service account of the relay pod")
+	flags.StringToString("node-selector", map[string]string{}, "key=value labels of the nodes the relay pod may be scheduled on")
+	flags.StringArray("annotation", []string{}, "key=value annotation added to the relay pod, e.g., to declare its commands")
+	flags.Int("local-port", 0, "Local port the relay is forwarded to, a random port when 0")
+	flags.Duration("ready-timeout", time.Minute, "Time to wait for the relay pod to start running")
+	flags.Duration("max-lifetime", relay.DefaultMaxLifetime, "Time after which the relay pod is stopped, should it not be deleted")
+
+	_ = cmd.MarkFlagRequired("to")
+
+	cmd.AddCommand(newRelayGCCommand(configFlags, streams))
+
+	return cmd
+}
+
+// newRelayGCCommand returns the command deleting orphaned relay pods.
+func newRelayGCCommand(configFlags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc [options]",
+		Short: "Delete relay pods left behind by sessions that did not exit cleanly",
+		Long: "Delete relay pods that have stopped, e.g., after reaching their maximum lifetime, and, with --older-than, " +
+			"relay pods created longer ago than the passed duration.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			flags := cmd.Flags()
+
+			olderThan, err := flags.GetDuration("older-than")
+			if err != nil {
+				return err
+			}
+
+			allNamespaces, err := flags.GetBool("all-namespaces")
+			if err != nil {
+				return err
+			}
+
+			namespace := ""
+			if !allNamespaces {
+				namespace, _, err = configFlags.ToRawKubeConfigLoader().Namespace()
+				if err != nil {
+					return err
+				}
+			}
+
+			clientset, err := newClientset(configFlags)
+			if err != nil {
+				return err
+			}
+
+			deleted, err := relay.New(clientset).GC(cmd.Context(), namespace, olderThan)
+
+			for _, pod := range deleted {
+				fmt.Fprintf(streams.Out, "Deleted relay pod %s/%s\n", pod.Namespace, pod.Name)
+			}
+
+			return err
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.Duration("older-than", 0, "Also delete running relay pods created longer ago than this duration")
+	flags.BoolP("all-namespaces", "A", false, "Delete relay pods of all namespaces")
+
+	return cmd
+}
+
+// parseRelayFlags returns the relay pod options configured by the command's flags, in the namespace of the current
+// context unless overridden.
+func parseRelayFlags(cmd *cobra.Command, configFlags *genericclioptions.ConfigFlags) (relay.Options, error) {
+	flags := cmd.Flags()
+	options := relay.Options{}
+
+	namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return options, err
+	}
+
+	options.Namespace = namespace
+
+	if options.To, err = flags.GetString("to"); err != nil {
+		return options, err
+	}
+
+	if options.Image, err = flags.GetString("image"); err != nil {
+		return options, err
+	}
+
+	if options.ServiceAccount, err = flags.GetString("service-account"); err != nil {
+		return options, err
+	}
+
+	if options.NodeSelector, err = flags.GetStringToString("node-selector"); err != nil {
+		return options, err
+	}
+
+	if options.MaxLifetime, err = flags.GetDuration("max-lifetime"); err != nil {
+		return options, err
+	}
+
+	annotations, err := flags.GetStringArray("annotation")
+	if err != nil {
+		return options, err
+	}
+
+	options.Annotations = map[string]string{}
+
+	// Annotation values are often JSON, so only the first = separates the key from the value.
+	for _, a := range annotations {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) != 2 {
+			return options, fmt.Errorf("annotation %q must be in key=value format", a)
+		}
+
+		options.Annotations[kv[0]] = kv[1]
+	}
+
+	return options, nil
+}
+
+// newClientset returns a Kubernetes clientset configured by the kubeconfig flags.
+func newClientset(configFlags *genericclioptions.ConfigFlags) (kubernetes.Interface, error) {
+	config, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// newWakeCommand returns the command grouping the maintenance of workloads woken up with --wake.
+func newWakeCommand(configFlags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams, version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wake",
+		Short: "Manage deployments and statefulsets woken up with --wake",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newWakeGCCommand(configFlags, streams, version))
+
+	return cmd
+}
+
+// newWakeGCCommand returns the command releasing workloads left awake by sessions that did not exit cleanly.
+func newWakeGCCommand(configFlags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams, version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc [options]",
+		Short: "Scale workloads left awake by sessions that did not exit cleanly back down",
+		Long: "Restore the original replicas of deployments and statefulsets woken up with --wake whose holds have all " +
+			"expired, e.g., because the sessions holding them were killed. Replicas changed since they were woken up are " +
+			"left as they are.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			allNamespaces, err := cmd.Flags().GetBool("all-namespaces")
+			if err != nil {
+				return err
+			}
+
+			namespace := ""
+			if !allNamespaces {
+				namespace, _, err = configFlags.ToRawKubeConfigLoader().Namespace()
+				if err != nil {
+					return err
+				}
+			}
+
+			client := forwarder.NewClient(0, streams)
+
+			if err := client.Init(configFlags, version); err != nil {
+				return err
+			}
+
+			released, err := client.WakeGC(cmd.Context(), namespace)
+
+			for _, w := range released {
+				fmt.Fprintf(streams.Out, "Released %s\n", w)
+			}
+
+			return err
+		},
+	}
+
+	cmd.Flags().BoolP("all-namespaces", "A", false, "Release workloads of all namespaces")
+
+	return cmd
+}
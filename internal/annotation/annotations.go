@@ -9,4 +9,36 @@ const (
 	PostConnect = "exec-forward.pod.kubernetes.io/post-connect"
 	// Command is the annotation key name used to store the main command to run after the post-connect hook has been run.
 	Command = "exec-forward.pod.kubernetes.io/command"
+	// Teardown is the annotation key name used to store commands run after the main command, before the portforward connection is closed.
+	Teardown = "exec-forward.pod.kubernetes.io/teardown"
+	// Permissions is the annotation key name used to store additional Kubernetes permissions the commands require.
+	Permissions = "exec-forward.pod.kubernetes.io/permissions"
+	// Signature is the annotation key name used to store a base64 encoded signature over the canonical form of the command annotations.
+	Signature = "exec-forward.pod.kubernetes.io/signature"
+	// IdleTimeout is the annotation key name used to store the longest time sessions may go without traffic, which the
+	// idle timeout requested by users cannot exceed.
+	IdleTimeout = "exec-forward.pod.kubernetes.io/idle-timeout"
+	// MaxDuration is the annotation key name used to store the longest time sessions may last, which the maximum
+	// duration requested by users cannot exceed.
+	MaxDuration = "exec-forward.pod.kubernetes.io/max-duration"
+
+	// WakeReplicas is the annotation key name of Deployments and StatefulSets storing the number of replicas they are
+	// scaled to when woken up from zero replicas, 1 when missing.
+	WakeReplicas = "exec-forward.pod.kubernetes.io/wake-replicas"
+	// WakeHolders is the annotation key name of woken up workloads storing the sessions keeping them awake. It is
+	// managed by the plugin.
+	WakeHolders = "exec-forward.pod.kubernetes.io/wake-holders"
+	// WakeOriginalReplicas is the annotation key name of woken up workloads storing the number of replicas restored once
+	// no session holds them anymore. It is managed by the plugin.
+	WakeOriginalReplicas = "exec-forward.pod.kubernetes.io/wake-original-replicas"
+	// WakeWokenReplicas is the annotation key name of woken up workloads storing the number of replicas they were scaled
+	// to, so that replicas changed by someone else while they were awake are not overwritten when they are released. It
+	// is managed by the plugin.
+	WakeWokenReplicas = "exec-forward.pod.kubernetes.io/wake-woken-replicas"
 )
+
+// commandKeys lists the annotation keys that describe the commands run by the plugin.
+var commandKeys = []string{Args, PreConnect, PostConnect, Command, Teardown, Permissions}
+
+// containerKeys lists the annotation keys that can be scoped to a container.
+var containerKeys = append([]string{Signature, IdleTimeout, MaxDuration}, commandKeys...)
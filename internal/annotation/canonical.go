@@ -0,0 +1,31 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Canonical returns the canonical form of the command annotations: a compact JSON object of the present command
+// annotation keys and their raw values, with keys sorted and without HTML escaping. Annotations unrelated to commands,
+// including the signature itself, are not included, so the result only changes when the commands that would be run
+// change. It matches the output of `jq -cjS` over the same object.
+func Canonical(annotations map[string]string) ([]byte, error) {
+	values := map[string]string{}
+
+	for _, key := range commandKeys {
+		if v, ok := annotations[key]; ok {
+			values[key] = v
+		}
+	}
+
+	b := new(bytes.Buffer)
+
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(values); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,63 @@
+package annotation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name: "sorted command annotations",
+			annotations: map[string]string{
+				Command:    `{"command":["psql"]}`,
+				Args:       `{"username":"read"}`,
+				PreConnect: `[{"command":["echo","pre"]}]`,
+			},
+			expected: `{"exec-forward.pod.kubernetes.io/args":"{\"username\":\"read\"}",` +
+				`"exec-forward.pod.kubernetes.io/command":"{\"command\":[\"psql\"]}",` +
+				`"exec-forward.pod.kubernetes.io/pre-connect":"[{\"command\":[\"echo\",\"pre\"]}]"}`,
+		},
+		{
+			name: "unrelated annotations ignored",
+			annotations: map[string]string{
+				"kubectl.kubernetes.io/restartedAt": "2022-10-01T00:00:00Z",
+				Command:                             `{"command":["psql"]}`,
+			},
+			expected: `{"exec-forward.pod.kubernetes.io/command":"{\"command\":[\"psql\"]}"}`,
+		},
+		{
+			name: "html characters are not escaped",
+			annotations: map[string]string{
+				Command:   `{"command":["sh","-c","psql < dump.sql && echo done"]}`,
+				Signature: "c2lnbmF0dXJl",
+			},
+			expected: `{"exec-forward.pod.kubernetes.io/command":"{\"command\":[\"sh\",\"-c\",\"psql < dump.sql && echo done\"]}"}`,
+		},
+		{
+			name:     "none",
+			expected: `{}`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := Canonical(tc.annotations)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, string(actual))
+		})
+	}
+}
@@ -2,6 +2,7 @@ package annotation
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/takescoop/kubectl-exec-forward/internal/command"
 )
@@ -17,5 +18,9 @@ func ParseCommand(annotations map[string]string) (command command.Command, err e
 		return command, err
 	}
 
+	if len(command.Command) == 0 {
+		return command, fmt.Errorf("annotation %s: command is empty", Command)
+	}
+
 	return command, nil
 }
@@ -32,6 +32,11 @@ func TestParseCommand(t *testing.T) {
 			annotations: map[string]string{Command: ""},
 			error:       "unexpected end of JSON input",
 		},
+		{
+			name:        "empty command",
+			annotations: map[string]string{Command: `{"command": []}`},
+			error:       "annotation " + Command + ": command is empty",
+		},
 	}
 
 	for _, tc := range cases {
@@ -2,6 +2,7 @@ package annotation
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/takescoop/kubectl-exec-forward/internal/command"
 )
@@ -17,5 +18,11 @@ func ParseCommands(annotations map[string]string, key string) (commands command.
 		return nil, err
 	}
 
+	for i, c := range commands {
+		if len(c.Command) == 0 {
+			return nil, fmt.Errorf("annotation %s: command %d is empty", key, i+1)
+		}
+	}
+
 	return commands, err
 }
@@ -53,6 +53,14 @@ func TestParseCommands(t *testing.T) {
 			key:   PreConnect,
 			error: "unexpected end of JSON input",
 		},
+		{
+			name: "empty command",
+			annotations: map[string]string{
+				PreConnect: `[{"command":["echo","pre"]},{"command":[]}]`,
+			},
+			key:   PreConnect,
+			error: "annotation " + PreConnect + ": command 2 is empty",
+		},
 	}
 
 	for _, tc := range cases {
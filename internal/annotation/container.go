@@ -0,0 +1,29 @@
+package annotation
+
+// ContainerKey returns the container-scoped variant of the passed annotation key, e.g.,
+// exec-forward.pod.kubernetes.io/pre-connect.sidecar for the "sidecar" container.
+func ContainerKey(key string, container string) string {
+	return key + "." + container
+}
+
+// ForContainer returns the annotations that apply to the passed container. Container-scoped annotations take
+// precedence over their pod-wide counterparts. When container is empty, the annotations are returned unchanged.
+func ForContainer(annotations map[string]string, container string) map[string]string {
+	if container == "" {
+		return annotations
+	}
+
+	scoped := map[string]string{}
+
+	for k, v := range annotations {
+		scoped[k] = v
+	}
+
+	for _, key := range containerKeys {
+		if v, ok := annotations[ContainerKey(key, container)]; ok {
+			scoped[key] = v
+		}
+	}
+
+	return scoped
+}
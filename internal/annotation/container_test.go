@@ -0,0 +1,66 @@
+package annotation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForContainer(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		container   string
+		expected    map[string]string
+	}{
+		{
+			name: "no container",
+			annotations: map[string]string{
+				PreConnect:                        `[{"command":["echo","pod"]}]`,
+				ContainerKey(PreConnect, "proxy"): `[{"command":["echo","proxy"]}]`,
+			},
+			expected: map[string]string{
+				PreConnect:                        `[{"command":["echo","pod"]}]`,
+				ContainerKey(PreConnect, "proxy"): `[{"command":["echo","proxy"]}]`,
+			},
+		},
+		{
+			name: "container overrides pod-wide annotation",
+			annotations: map[string]string{
+				PreConnect:                        `[{"command":["echo","pod"]}]`,
+				Command:                           `{"command":["psql"]}`,
+				ContainerKey(PreConnect, "proxy"): `[{"command":["echo","proxy"]}]`,
+			},
+			container: "proxy",
+			expected: map[string]string{
+				PreConnect:                        `[{"command":["echo","proxy"]}]`,
+				Command:                           `{"command":["psql"]}`,
+				ContainerKey(PreConnect, "proxy"): `[{"command":["echo","proxy"]}]`,
+			},
+		},
+		{
+			name: "other containers are ignored",
+			annotations: map[string]string{
+				Args:                           `{"username":"pod"}`,
+				ContainerKey(Args, "exporter"): `{"username":"exporter"}`,
+			},
+			container: "proxy",
+			expected: map[string]string{
+				Args:                           `{"username":"pod"}`,
+				ContainerKey(Args, "exporter"): `{"username":"exporter"}`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, ForContainer(tc.annotations, tc.container))
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package annotation
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits are ceilings enforced on sessions, which users cannot exceed. Zero values leave sessions unbounded.
+type Limits struct {
+	// IdleTimeout is the longest time a session may go without traffic through its forwarded ports.
+	IdleTimeout time.Duration
+	// MaxDuration is the longest time a session may last once connected.
+	MaxDuration time.Duration
+}
+
+// ParseLimits returns the limits enforced on sessions, parsed from durations such as 30m or 8h.
+func ParseLimits(annotations map[string]string) (Limits, error) {
+	idleTimeout, err := parseDuration(annotations, IdleTimeout)
+	if err != nil {
+		return Limits{}, err
+	}
+
+	maxDuration, err := parseDuration(annotations, MaxDuration)
+	if err != nil {
+		return Limits{}, err
+	}
+
+	return Limits{IdleTimeout: idleTimeout, MaxDuration: maxDuration}, nil
+}
+
+// parseDuration parses the positive duration stored under key, or returns zero when missing.
+func parseDuration(annotations map[string]string, key string) (time.Duration, error) {
+	v, ok := annotations[key]
+	if !ok {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("annotation %s: %w", key, err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("annotation %s must be a positive duration, got %s", key, v)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,59 @@
+package annotation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimits(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expected    Limits
+		error       string
+	}{
+		{
+			name:        "basic",
+			annotations: map[string]string{IdleTimeout: "30m", MaxDuration: "8h"},
+			expected:    Limits{IdleTimeout: 30 * time.Minute, MaxDuration: 8 * time.Hour},
+		},
+		{
+			name:        "none",
+			annotations: map[string]string{},
+		},
+		{
+			name:        "invalid duration",
+			annotations: map[string]string{IdleTimeout: "30"},
+			error:       `annotation exec-forward.pod.kubernetes.io/idle-timeout: time: missing unit in duration "30"`,
+		},
+		{
+			name:        "negative duration",
+			annotations: map[string]string{MaxDuration: "-1h"},
+			error:       "annotation exec-forward.pod.kubernetes.io/max-duration must be a positive duration, got -1h",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseLimits(tc.annotations)
+
+			if tc.error != "" {
+				assert.EqualError(t, err, tc.error)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package annotation
+
+import (
+	"encoding/json"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+)
+
+// Merge returns the annotations overridden by overrides, such as those declared by a session spec file. Each command
+// annotation present in overrides replaces the annotation's, except args which are merged by key. Since the signature
+// no longer covers the commands once one of them is overridden, it is then dropped.
+func Merge(annotations map[string]string, overrides map[string]string) (map[string]string, error) {
+	merged := map[string]string{}
+
+	for k, v := range annotations {
+		merged[k] = v
+	}
+
+	overridden := false
+
+	for _, key := range commandKeys {
+		v, ok := overrides[key]
+		if !ok {
+			continue
+		}
+
+		overridden = true
+
+		if key != Args {
+			merged[key] = v
+
+			continue
+		}
+
+		args, err := ParseArgs(annotations)
+		if err != nil {
+			return nil, err
+		}
+
+		extra := command.Args{}
+		if err := json.Unmarshal([]byte(v), &extra); err != nil {
+			return nil, err
+		}
+
+		args.Merge(extra)
+
+		b, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+
+		merged[key] = string(b)
+	}
+
+	if overridden {
+		delete(merged, Signature)
+	}
+
+	return merged, nil
+}
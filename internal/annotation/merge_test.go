@@ -0,0 +1,85 @@
+package annotation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		overrides   map[string]string
+		expected    map[string]string
+		error       bool
+	}{
+		{
+			name: "no overrides",
+			annotations: map[string]string{
+				PreConnect: `[{"command":["echo","pod"]}]`,
+				Signature:  "c2lnbmF0dXJl",
+			},
+			overrides: map[string]string{},
+			expected: map[string]string{
+				PreConnect: `[{"command":["echo","pod"]}]`,
+				Signature:  "c2lnbmF0dXJl",
+			},
+		},
+		{
+			name: "overrides replace stages and drop the signature",
+			annotations: map[string]string{
+				PreConnect: `[{"command":["echo","pod"]}]`,
+				Command:    `{"command":["psql"]}`,
+				Signature:  "c2lnbmF0dXJl",
+			},
+			overrides: map[string]string{
+				PreConnect: `[{"command":["echo","spec"]}]`,
+			},
+			expected: map[string]string{
+				PreConnect: `[{"command":["echo","spec"]}]`,
+				Command:    `{"command":["psql"]}`,
+			},
+		},
+		{
+			name: "args are merged by key",
+			annotations: map[string]string{
+				Args: `{"username":"pod","database":"app"}`,
+			},
+			overrides: map[string]string{
+				Args: `{"username":"spec"}`,
+			},
+			expected: map[string]string{
+				Args: `{"database":"app","username":"spec"}`,
+			},
+		},
+		{
+			name:        "invalid args",
+			annotations: map[string]string{},
+			overrides:   map[string]string{Args: "invalid"},
+			error:       true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			merged, err := Merge(tc.annotations, tc.overrides)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, merged)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package annotation
+
+import (
+	"encoding/json"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// ParsePermissions returns the Kubernetes permissions required by the commands, parsed from a JSON list of resource
+// attributes, e.g., [{"verb":"get","resource":"secrets","name":"db"}].
+func ParsePermissions(annotations map[string]string) (permissions []authorizationv1.ResourceAttributes, err error) {
+	v, ok := annotations[Permissions]
+	if !ok {
+		return permissions, nil
+	}
+
+	if err := json.Unmarshal([]byte(v), &permissions); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,59 @@
+package annotation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+func TestParsePermissions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expected    []authorizationv1.ResourceAttributes
+		error       string
+	}{
+		{
+			name: "basic",
+			annotations: map[string]string{
+				Permissions: `[{"verb":"get","resource":"secrets","name":"db"},{"verb":"create","resource":"pods","subresource":"exec"}]`,
+			},
+			expected: []authorizationv1.ResourceAttributes{
+				{Verb: "get", Resource: "secrets", Name: "db"},
+				{Verb: "create", Resource: "pods", Subresource: "exec"},
+			},
+		},
+		{
+			name:        "none",
+			annotations: map[string]string{},
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{Permissions: ""},
+			error:       "unexpected end of JSON input",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParsePermissions(tc.annotations)
+
+			if tc.error != "" {
+				assert.EqualError(t, err, tc.error)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -1,11 +1,17 @@
 package attachablepod
 
 import (
+	"context"
+	"io"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/kubectl/pkg/polymorphichelpers"
 	"k8s.io/kubectl/pkg/scheme"
 )
@@ -13,6 +19,9 @@ import (
 // Client is an attachable pod client.
 type Client struct {
 	getter genericclioptions.RESTClientGetter
+
+	// Progress receives messages about the pods being waited for. When nil, progress is not reported.
+	Progress io.Writer
 }
 
 // New constructs a new attachable pod client from the given factory.
@@ -22,9 +31,61 @@ func New(getter genericclioptions.RESTClientGetter) *Client {
 
 // Get resolves a pod from a resource string and namespace, within the specified timeout. A resource is specified in
 // kubectl syntax: <resource>/<name>. It can be a pod or a an object with pod selectors like Service or Deployment. It
-// returns the directly referenced object with the requested resource type and the first attachable pod.
+// returns the directly referenced object with the requested resource type and the first attachable pod. Pods are
+// watched until one of them is running, failing early when none of them can start without intervention.
 func (c *Client) Get(resourceName string, namespace string, timeout time.Duration) (interface{}, *v1.Pod, error) {
-	obj, err := resource.NewBuilder(c.getter).
+	return c.GetContext(context.Background(), resourceName, namespace, timeout)
+}
+
+// GetContext is like Get, but stops waiting for pods when ctx is done.
+func (c *Client) GetContext(ctx context.Context, resourceName string, namespace string, timeout time.Duration) (interface{}, *v1.Pod, error) {
+	obj, err := c.Object(resourceName, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := metav1.ListOptions{}
+
+	if pod, ok := obj.(*v1.Pod); ok {
+		if _, err := podStatus(pod); err != nil {
+			return obj, nil, err
+		}
+
+		if attachable(pod) {
+			return obj, pod, nil
+		}
+
+		namespace = pod.Namespace
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", pod.Name).String()
+	} else {
+		ns, selector, err := polymorphichelpers.SelectorsForObject(obj)
+		if err != nil {
+			return obj, nil, err
+		}
+
+		namespace = ns
+		options.LabelSelector = selector.String()
+	}
+
+	config, err := c.getter.ToRESTConfig()
+	if err != nil {
+		return obj, nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return obj, nil, err
+	}
+
+	pod, err := waitForPod(ctx, clientset.CoreV1().Pods(namespace), options, resourceName, timeout, c.progress())
+
+	return obj, pod, err
+}
+
+// Object resolves the object referenced by a resource string in kubectl syntax, <resource>/<name>, without looking up
+// its pods.
+func (c *Client) Object(resourceName string, namespace string) (runtime.Object, error) {
+	return resource.NewBuilder(c.getter).
 		WithScheme(scheme.Scheme, scheme.Scheme.PrioritizedVersionsAllGroups()...).
 		ContinueOnError().
 		NamespaceParam(namespace).
@@ -32,11 +93,13 @@ func (c *Client) Get(resourceName string, namespace string, timeout time.Duratio
 		ResourceNames("pods", resourceName).
 		Do().
 		Object()
-	if err != nil {
-		return nil, nil, err
-	}
+}
 
-	pod, err := polymorphichelpers.AttachablePodForObjectFn(c.getter, obj, timeout)
+// progress returns the writer progress messages are written to.
+func (c *Client) progress() io.Writer {
+	if c.Progress != nil {
+		return c.Progress
+	}
 
-	return obj, pod, err
+	return io.Discard
 }
@@ -39,6 +39,7 @@ func TestClientGet(t *testing.T) {
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "foo",
 					},
+					Status: v1.PodStatus{Phase: v1.PodRunning},
 				},
 			},
 
@@ -46,11 +47,13 @@ func TestClientGet(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "foo",
 				},
+				Status: v1.PodStatus{Phase: v1.PodRunning},
 			},
 			object: &v1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "foo",
 				},
+				Status: v1.PodStatus{Phase: v1.PodRunning},
 			},
 		},
 		{
@@ -77,6 +80,7 @@ func TestClientGet(t *testing.T) {
 							ObjectMeta: metav1.ObjectMeta{
 								Name: "foo",
 							},
+							Status: v1.PodStatus{Phase: v1.PodRunning},
 						},
 					},
 				},
@@ -84,6 +88,7 @@ func TestClientGet(t *testing.T) {
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "foo",
 					},
+					Status: v1.PodStatus{Phase: v1.PodRunning},
 				},
 			},
 
@@ -91,6 +96,7 @@ func TestClientGet(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "foo",
 				},
+				Status: v1.PodStatus{Phase: v1.PodRunning},
 			},
 			object: &appsv1.Deployment{
 				ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,285 @@
+package attachablepod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/kubectl/pkg/util/podutils"
+)
+
+// fatalReasons are the reasons of waiting containers that keep a pod from starting until someone intervenes, such as
+// a missing image or a crashing process.
+var fatalReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// PodError is returned when no pod can become attachable without intervention.
+type PodError struct {
+	Pod     string
+	Status  string
+	Message string
+}
+
+// Error describes why the pod cannot start.
+func (e *PodError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("pod %s cannot start: %s", e.Pod, e.Status)
+	}
+
+	return fmt.Sprintf("pod %s cannot start: %s: %s", e.Pod, e.Status, e.Message)
+}
+
+// podStatus returns a short description of the pod's progress, similar to the STATUS column of kubectl get pods. When
+// the pod cannot start without intervention, the returned error explains why. Containers of running pods waiting for
+// fatal reasons, e.g., crash looping sidecars, are reported without failing, since the forwarded port may be served by
+// another container.
+func podStatus(pod *v1.Pod) (string, error) {
+	if pod.DeletionTimestamp != nil {
+		return "Terminating", nil
+	}
+
+	switch pod.Status.Phase {
+	case v1.PodSucceeded, v1.PodFailed:
+		return string(pod.Status.Phase), &PodError{Pod: pod.Name, Status: string(pod.Status.Phase), Message: pod.Status.Message}
+	case v1.PodRunning:
+		if podutils.IsPodReady(pod) {
+			return "Ready", nil
+		}
+	}
+
+	for _, statuses := range []struct {
+		prefix   string
+		statuses []v1.ContainerStatus
+	}{
+		{prefix: "Init:", statuses: pod.Status.InitContainerStatuses},
+		{prefix: "", statuses: pod.Status.ContainerStatuses},
+	} {
+		for _, s := range statuses.statuses {
+			waiting := s.State.Waiting
+			if waiting == nil || waiting.Reason == "" || (statuses.prefix == "" && waiting.Reason == "PodInitializing") {
+				continue
+			}
+
+			status := statuses.prefix + waiting.Reason
+			if fatalReasons[waiting.Reason] && pod.Status.Phase != v1.PodRunning {
+				return status, &PodError{Pod: pod.Name, Status: status, Message: waiting.Message}
+			}
+
+			return status, nil
+		}
+	}
+
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse && c.Reason != "" {
+			return fmt.Sprintf("%s (%s)", pod.Status.Phase, c.Reason), nil
+		}
+	}
+
+	if pod.Status.Phase == "" {
+		return string(v1.PodPending), nil
+	}
+
+	return string(pod.Status.Phase), nil
+}
+
+// ContainerError returns a PodError when the named container of the pod waits for a reason that keeps it from running
+// without intervention, such as a crash loop. Unknown containers are not an error.
+func ContainerError(pod *v1.Pod, container string) error {
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.Name != container || s.State.Waiting == nil || !fatalReasons[s.State.Waiting.Reason] {
+			continue
+		}
+
+		return &PodError{
+			Pod:     pod.Name,
+			Status:  fmt.Sprintf("container %s is %s", container, s.State.Waiting.Reason),
+			Message: s.State.Waiting.Message,
+		}
+	}
+
+	return nil
+}
+
+// attachable returns whether connections can be forwarded to the pod.
+func attachable(pod *v1.Pod) bool {
+	return pod.DeletionTimestamp == nil && pod.Status.Phase == v1.PodRunning
+}
+
+// podWaiter tracks the pods matching a selector, reporting their progress, until one of them is attachable.
+type podWaiter struct {
+	target   string
+	out      io.Writer
+	pods     map[string]*v1.Pod
+	statuses map[string]string
+	// report enables progress messages. It is only set once the pods are known not to be attachable yet, so that the
+	// common case stays quiet.
+	report bool
+}
+
+// record stores the latest state of a pod.
+func (w *podWaiter) record(pod *v1.Pod, deleted bool) {
+	if deleted {
+		delete(w.pods, pod.Name)
+		delete(w.statuses, pod.Name)
+
+		return
+	}
+
+	w.pods[pod.Name] = pod
+}
+
+// check returns an attachable pod, if any, reporting status changes. It fails once every pod is unable to start
+// without intervention.
+func (w *podWaiter) check() (*v1.Pod, error) {
+	candidates := []*v1.Pod{}
+	fatal := 0
+
+	var podErr error
+
+	for _, name := range w.names() {
+		p := w.pods[name]
+
+		status, err := podStatus(p)
+		if w.report && status != w.statuses[name] {
+			fmt.Fprintf(w.out, "Waiting for %s: pod %s is %s\n", w.target, name, status)
+		}
+
+		w.statuses[name] = status
+
+		if err != nil {
+			podErr = err
+			fatal++
+
+			continue
+		}
+
+		if attachable(p) {
+			candidates = append(candidates, p)
+		}
+	}
+
+	if len(candidates) > 0 {
+		sort.Sort(sort.Reverse(podutils.ActivePods(candidates)))
+
+		return candidates[0], nil
+	}
+
+	if fatal > 0 && fatal == len(w.pods) {
+		return nil, podErr
+	}
+
+	return nil, nil
+}
+
+// names returns the names of the tracked pods in a stable order.
+func (w *podWaiter) names() []string {
+	names := make([]string, 0, len(w.pods))
+	for name := range w.pods {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// waitForPod waits for a pod matching options to be attachable, within timeout, printing the progress of the pods to
+// out. Pods that cannot start without intervention, e.g., because their image cannot be pulled, fail the wait right
+// away. target describes the object the pods belong to in progress messages, e.g., "deployment/db". The wait stops
+// when ctx is done.
+func waitForPod(ctx context.Context, pods corev1client.PodInterface, options metav1.ListOptions, target string, timeout time.Duration, out io.Writer) (*v1.Pod, error) {
+	w := &podWaiter{target: target, out: out, pods: map[string]*v1.Pod{}, statuses: map[string]string{}}
+
+	// Like kubectl, pods that are already running are found regardless of the timeout, which only bounds the wait.
+	list, err := pods.List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range list.Items {
+		w.record(&list.Items[i], false)
+	}
+
+	if pod, err := w.check(); pod != nil || err != nil {
+		return pod, err
+	}
+
+	w.report = true
+	w.statuses = map[string]string{}
+
+	if len(list.Items) == 0 {
+		fmt.Fprintf(w.out, "Waiting for %s: no pod found yet\n", target)
+	} else if _, err := w.check(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	watchOptions := options
+	watchOptions.ResourceVersion = list.ResourceVersion
+
+	watcher, err := pods.Watch(ctx, watchOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *v1.Pod
+
+	_, err = watchtools.UntilWithoutRetry(ctx, watcher, func(event watch.Event) (bool, error) {
+		pod, ok := event.Object.(*v1.Pod)
+		if !ok {
+			return false, nil
+		}
+
+		w.record(pod, event.Type == watch.Deleted)
+
+		found, err = w.check()
+
+		return found != nil, err
+	})
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, fmt.Errorf("waiting for an attachable pod of %s: %w", target, ctx.Err())
+		}
+
+		if errors.Is(err, wait.ErrWaitTimeout) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s waiting for an attachable pod of %s%s", timeout, target, w.lastStatuses())
+		}
+
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// lastStatuses summarizes the last known status of each pod, for timeout errors.
+func (w *podWaiter) lastStatuses() string {
+	if len(w.pods) == 0 {
+		return ", no pod found"
+	}
+
+	statuses := []string{}
+	for _, name := range w.names() {
+		statuses = append(statuses, fmt.Sprintf("%s is %s", name, w.statuses[name]))
+	}
+
+	return ": " + strings.Join(statuses, ", ")
+}
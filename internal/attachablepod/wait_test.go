@@ -0,0 +1,357 @@
+package attachablepod
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// waitingPod returns a pending pod whose container waits for the passed reason.
+func waitingPod(name string, reason string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "db", Labels: map[string]string{"app": "db"}},
+		Status: v1.PodStatus{
+			Phase: v1.PodPending,
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "postgres", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason, Message: "details"}}},
+			},
+		},
+	}
+}
+
+// crashingSidecarPod returns a running pod whose postgres container runs while its sidecar crash loops.
+func crashingSidecarPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "db", Labels: map[string]string{"app": "db"}},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "postgres", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+				{Name: "exporter", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "details"}}},
+			},
+		},
+	}
+}
+
+// readyPod returns a running and ready pod.
+func readyPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "db", Labels: map[string]string{"app": "db"}},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func TestPodStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		pod *v1.Pod
+
+		status string
+		error  bool
+	}{
+		{
+			name:   "pending",
+			pod:    &v1.Pod{Status: v1.PodStatus{Phase: v1.PodPending}},
+			status: "Pending",
+		},
+		{
+			name: "unschedulable",
+			pod: &v1.Pod{Status: v1.PodStatus{
+				Phase:      v1.PodPending,
+				Conditions: []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: "Unschedulable"}},
+			}},
+			status: "Pending (Unschedulable)",
+		},
+		{
+			name:   "creating containers",
+			pod:    waitingPod("db-0", "ContainerCreating"),
+			status: "ContainerCreating",
+		},
+		{
+			name: "initializing",
+			pod: &v1.Pod{Status: v1.PodStatus{
+				Phase: v1.PodPending,
+				InitContainerStatuses: []v1.ContainerStatus{
+					{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+				},
+			}},
+			status: "Init:ImagePullBackOff",
+			error:  true,
+		},
+		{
+			name:   "image pull error",
+			pod:    waitingPod("db-0", "ImagePullBackOff"),
+			status: "ImagePullBackOff",
+			error:  true,
+		},
+		{
+			name:   "crash loop",
+			pod:    waitingPod("db-0", "CrashLoopBackOff"),
+			status: "CrashLoopBackOff",
+			error:  true,
+		},
+		{
+			name:   "running",
+			pod:    &v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}},
+			status: "Running",
+		},
+		{
+			name:   "running with a crash looping sidecar",
+			pod:    crashingSidecarPod("db-0"),
+			status: "CrashLoopBackOff",
+		},
+		{
+			name:   "ready",
+			pod:    readyPod("db-0"),
+			status: "Ready",
+		},
+		{
+			name:   "failed",
+			pod:    &v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed}},
+			status: "Failed",
+			error:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			status, err := podStatus(tc.pod)
+
+			assert.Equal(t, tc.status, status)
+
+			if tc.error {
+				var podErr *PodError
+
+				assert.ErrorAs(t, err, &podErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWaitForPod(t *testing.T) {
+	t.Parallel()
+
+	pods := v1.SchemeGroupVersion.WithResource("pods")
+
+	cases := []struct {
+		name string
+
+		initial []runtime.Object
+		updates []*v1.Pod
+
+		// cancel cancels the wait once the pods are watched.
+		cancel bool
+
+		pod      string
+		progress []string
+		error    bool
+	}{
+		{
+			name:    "running pod",
+			initial: []runtime.Object{readyPod("db-0")},
+			pod:     "db-0",
+		},
+		{
+			name:    "pod becoming ready",
+			initial: []runtime.Object{&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db", Labels: map[string]string{"app": "db"}}}},
+			updates: []*v1.Pod{waitingPod("db-0", "ContainerCreating"), readyPod("db-0")},
+			pod:     "db-0",
+			progress: []string{
+				"Waiting for deployment/db: pod db-0 is Pending",
+				"Waiting for deployment/db: pod db-0 is ContainerCreating",
+				"Waiting for deployment/db: pod db-0 is Ready",
+			},
+		},
+		{
+			name:    "pod created",
+			updates: []*v1.Pod{readyPod("db-1")},
+			pod:     "db-1",
+			progress: []string{
+				"Waiting for deployment/db: no pod found yet",
+				"Waiting for deployment/db: pod db-1 is Ready",
+			},
+		},
+		{
+			name:    "image pull error",
+			initial: []runtime.Object{waitingPod("db-0", "ContainerCreating")},
+			updates: []*v1.Pod{waitingPod("db-0", "ImagePullBackOff")},
+			progress: []string{
+				"Waiting for deployment/db: pod db-0 is ContainerCreating",
+				"Waiting for deployment/db: pod db-0 is ImagePullBackOff",
+			},
+			error: true,
+		},
+		{
+			name:    "crash loop while another pod starts",
+			initial: []runtime.Object{waitingPod("db-0", "CrashLoopBackOff"), waitingPod("db-1", "ContainerCreating")},
+			updates: []*v1.Pod{readyPod("db-1")},
+			pod:     "db-1",
+			progress: []string{
+				"Waiting for deployment/db: pod db-0 is CrashLoopBackOff",
+				"Waiting for deployment/db: pod db-1 is ContainerCreating",
+				"Waiting for deployment/db: pod db-1 is Ready",
+			},
+		},
+		{
+			name:    "running pod with a crash looping sidecar",
+			initial: []runtime.Object{crashingSidecarPod("db-0")},
+			pod:     "db-0",
+		},
+		{
+			name:    "timeout",
+			initial: []runtime.Object{waitingPod("db-0", "ContainerCreating")},
+			progress: []string{
+				"Waiting for deployment/db: pod db-0 is ContainerCreating",
+			},
+			error: true,
+		},
+		{
+			name:    "canceled",
+			initial: []runtime.Object{waitingPod("db-0", "ContainerCreating")},
+			cancel:  true,
+			progress: []string{
+				"Waiting for deployment/db: pod db-0 is ContainerCreating",
+			},
+			error: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset(tc.initial...)
+
+			watching := make(chan struct{})
+
+			clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+				w, err := clientset.Tracker().Watch(pods, "db")
+				close(watching)
+
+				return true, w, err
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Updates are applied once the pods are watched, as the fake clientset does not replay missed events.
+			go func() {
+				<-watching
+
+				if tc.cancel {
+					cancel()
+				}
+
+				for _, pod := range tc.updates {
+					var err error
+					if _, getErr := clientset.Tracker().Get(pods, "db", pod.Name); getErr == nil {
+						err = clientset.Tracker().Update(pods, pod, "db")
+					} else {
+						err = clientset.Tracker().Create(pods, pod, "db")
+					}
+
+					assert.NoError(t, err)
+				}
+			}()
+
+			out := &bytes.Buffer{}
+
+			pod, err := waitForPod(ctx, clientset.CoreV1().Pods("db"), metav1.ListOptions{LabelSelector: "app=db"}, "deployment/db", time.Second, out)
+
+			progress := []string{}
+			for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+				if len(line) > 0 {
+					progress = append(progress, string(line))
+				}
+			}
+
+			if tc.cancel {
+				assert.ErrorIs(t, err, context.Canceled)
+			} else if tc.error {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.pod, pod.Name)
+			}
+
+			if tc.progress == nil {
+				tc.progress = []string{}
+			}
+
+			assert.Equal(t, tc.progress, progress)
+		})
+	}
+}
+
+func TestContainerError(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		container string
+
+		error string
+	}{
+		{
+			name:      "running container",
+			container: "postgres",
+		},
+		{
+			name:      "crash looping container",
+			container: "exporter",
+			error:     "pod db-0 cannot start: container exporter is CrashLoopBackOff: details",
+		},
+		{
+			name:      "unknown container",
+			container: "",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ContainerError(crashingSidecarPod("db-0"), tc.container)
+
+			if tc.error != "" {
+				var podErr *PodError
+
+				assert.ErrorAs(t, err, &podErr)
+				assert.EqualError(t, err, tc.error)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheOptions configures how long a command's output is reused across invocations.
+type CacheOptions struct {
+	// TTL is how long the output remains valid, e.g., "10m".
+	TTL Duration `json:"ttl"`
+	// Key is a template rendered with the command's template data that distinguishes cached outputs, e.g., "{{.Args.username}}".
+	Key string `json:"key"`
+}
+
+// Duration is a time.Duration that is decoded from a JSON duration string, e.g., "10m".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// MarshalJSON formats the duration as a duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Cache stores command outputs across invocations.
+type Cache interface {
+	// Get returns the output stored at key, if any and not yet expired.
+	Get(key string) ([]byte, bool)
+	// Set stores the output at key for the given duration.
+	Set(key string, output []byte, ttl time.Duration) error
+}
+
+// DefaultCacheDir returns the default directory used to cache command outputs.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "kubectl-exec-forward")
+}
+
+// FileCache is a Cache storing each output in a file only accessible by the current user.
+type FileCache struct {
+	dir string
+	now func() time.Time
+}
+
+// NewFileCache returns a cache storing outputs in the passed directory.
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{dir: dir, now: time.Now}
+}
+
+// cacheEntry is the on-disk representation of a cached output.
+type cacheEntry struct {
+	Expires time.Time `json:"expires"`
+	Output  []byte    `json:"output"`
+}
+
+// Get returns the output stored at key. Expired outputs are removed.
+func (c *FileCache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	entry := cacheEntry{}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, false
+	}
+
+	if !c.now().Before(entry.Expires) {
+		_ = os.Remove(path)
+
+		return nil, false
+	}
+
+	return entry.Output, true
+}
+
+// Set stores the output at key for the given duration.
+func (c *FileCache) Set(key string, output []byte, ttl time.Duration) error {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(cacheEntry{Expires: c.now().Add(ttl), Output: output})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// path returns the file storing the output at key.
+func (c *FileCache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// cacheKey returns the key a command's output is cached at, derived from the cache scope, the command itself, its
+// rendered arguments and its rendered cache key. Outputs of commands rendered for another user or port, e.g., are
+// therefore not reused. It returns false when the command's output should not be cached.
+func (c Command) cacheKey(config *Config, data TemplateData) (string, bool, error) {
+	if c.Cache == nil || config.Cache == nil || c.Interactive || c.Cache.TTL <= 0 {
+		return "", false, nil
+	}
+
+	key, err := c.render(c.Cache.Key, data, TemplateOptions{ShowSensitive: true})
+	if err != nil {
+		return "", false, fmt.Errorf("rendering cache key: %w", err)
+	}
+
+	spec, err := json.Marshal(c)
+	if err != nil {
+		return "", false, err
+	}
+
+	argv, err := c.argv(data)
+	if err != nil {
+		return "", false, err
+	}
+
+	rendered, err := json.Marshal(argv)
+	if err != nil {
+		return "", false, err
+	}
+
+	h := sha256.New()
+
+	for _, part := range [][]byte{[]byte(config.CacheScope), spec, rendered, []byte(key)} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), true, nil
+}
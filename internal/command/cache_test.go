@@ -0,0 +1,120 @@
+package command
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheOptionsUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	c := Command{}
+	require.NoError(t, json.Unmarshal([]byte(`{"command":["echo"],"cache":{"ttl":"10m","key":"{{.Args.username}}"}}`), &c))
+
+	assert.Equal(t, &CacheOptions{TTL: Duration(10 * time.Minute), Key: "{{.Args.username}}"}, c.Cache)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"cache":{"ttl":"10 minutes"}}`), &c))
+}
+
+func TestFileCache(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	now := time.Now()
+
+	cache := NewFileCache(dir)
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.Get("foo")
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Set("foo", []byte("bar"), time.Minute))
+
+	output, ok := cache.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("bar"), output)
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Join(dir, "foo"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	now = now.Add(time.Minute)
+
+	_, ok = cache.Get("foo")
+	assert.False(t, ok)
+
+	_, err = os.Stat(filepath.Join(dir, "foo"))
+	assert.True(t, os.IsNotExist(err), "expired output was not removed")
+}
+
+func TestCommandCacheKey(t *testing.T) {
+	t.Parallel()
+
+	cache := NewFileCache(t.TempDir())
+	command := Command{
+		Command: []string{"aws", "rds", "generate-db-auth-token"},
+		Cache:   &CacheOptions{TTL: Duration(time.Minute), Key: "{{.Args.username}}"},
+	}
+
+	key := func(t *testing.T, c Command, config *Config, args Args) (string, bool) {
+		t.Helper()
+
+		k, ok, err := c.cacheKey(config, TemplateData{Args: args})
+		require.NoError(t, err)
+
+		return k, ok
+	}
+
+	// Commands rendered differently are cached apart, even with a static cache key.
+	static := Command{
+		Command: []string{"aws", "rds", "generate-db-auth-token", "--username", "{{.Args.username}}", "--port", "{{.LocalPort}}"},
+		Cache:   &CacheOptions{TTL: Duration(time.Minute)},
+	}
+
+	staticKey, _, err := static.cacheKey(&Config{Cache: cache}, TemplateData{Args: Args{"username": "read"}, LocalPort: 5432})
+	require.NoError(t, err)
+
+	staticOtherUser, _, err := static.cacheKey(&Config{Cache: cache}, TemplateData{Args: Args{"username": "write"}, LocalPort: 5432})
+	require.NoError(t, err)
+	assert.NotEqual(t, staticKey, staticOtherUser)
+
+	staticOtherPort, _, err := static.cacheKey(&Config{Cache: cache}, TemplateData{Args: Args{"username": "read"}, LocalPort: 15432})
+	require.NoError(t, err)
+	assert.NotEqual(t, staticKey, staticOtherPort)
+
+	base, ok := key(t, command, &Config{Cache: cache, CacheScope: "cluster/db/StatefulSet/db"}, Args{"username": "read"})
+	assert.True(t, ok)
+
+	same, _ := key(t, command, &Config{Cache: cache, CacheScope: "cluster/db/StatefulSet/db"}, Args{"username": "read"})
+	assert.Equal(t, base, same)
+
+	otherUser, _ := key(t, command, &Config{Cache: cache, CacheScope: "cluster/db/StatefulSet/db"}, Args{"username": "write"})
+	assert.NotEqual(t, base, otherUser)
+
+	otherScope, _ := key(t, command, &Config{Cache: cache, CacheScope: "cluster/web/StatefulSet/db"}, Args{"username": "read"})
+	assert.NotEqual(t, base, otherScope)
+
+	changed := command
+	changed.Command = []string{"aws", "rds", "generate-db-auth-token", "--region", "us-west-2"}
+	otherCommand, _ := key(t, changed, &Config{Cache: cache, CacheScope: "cluster/db/StatefulSet/db"}, Args{"username": "read"})
+	assert.NotEqual(t, base, otherCommand)
+
+	_, ok = key(t, command, &Config{}, Args{"username": "read"})
+	assert.False(t, ok, "cached without a cache")
+
+	_, ok = key(t, Command{Command: []string{"echo"}}, &Config{Cache: cache}, Args{})
+	assert.False(t, ok, "cached without cache options")
+
+	_, _, err = command.cacheKey(&Config{Cache: cache}, TemplateData{})
+	assert.Error(t, err)
+}
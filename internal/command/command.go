@@ -16,17 +16,27 @@ import (
 
 // Command represents a runnable command.
 type Command struct {
-	ID          string   `json:"id"`
-	Command     []string `json:"command"`
-	Interactive bool     `json:"interactive"`
-	DisplayName string   `json:"name"`
+	ID          string        `json:"id"`
+	Command     []string      `json:"command"`
+	Interactive bool          `json:"interactive"`
+	DisplayName string        `json:"name"`
+	Cache       *CacheOptions `json:"cache,omitempty"`
 }
 
 // TemplateData is the data passed to command templates to render the command arguments.
 type TemplateData struct {
 	LocalPort int
-	Args      Args
-	Outputs   map[string]string
+	// ProxyAddr is the address of the local SOCKS5 proxy of proxy sessions, e.g., "127.0.0.1:1080".
+	ProxyAddr string
+	// Targets are the targets of the session by name, e.g., {{.Targets.redis.LocalPort}}.
+	Targets map[string]Target
+	Args    Args
+	Outputs map[string]string
+}
+
+// Target is a resource forwarded to by a session, as exposed to command templates.
+type Target struct {
+	LocalPort int
 }
 
 // TemplateOptions are the configurable options used in different rendering contexts.
@@ -50,25 +60,35 @@ func (c Command) Args(data TemplateData, options TemplateOptions) ([]string, err
 	copy(args, c.Command[1:])
 
 	for i, raw := range args {
-		tpl, err := template.New(c.ID).Option("missingkey=error").Funcs(template.FuncMap{
-			"trim":      strings.TrimSpace,
-			"json":      gjson.Get,
-			"sensitive": sensitiveFunc(options.ShowSensitive),
-		}).Parse(raw)
+		arg, err := c.render(raw, data, options)
 		if err != nil {
 			return nil, err
 		}
 
-		o := new(bytes.Buffer)
+		args[i] = arg
+	}
 
-		if err := tpl.Execute(o, data); err != nil {
-			return nil, err
-		}
+	return args, nil
+}
+
+// render renders a single template string using the provided template data and options.
+func (c Command) render(raw string, data TemplateData, options TemplateOptions) (string, error) {
+	tpl, err := template.New(c.ID).Option("missingkey=error").Funcs(template.FuncMap{
+		"trim":      strings.TrimSpace,
+		"json":      gjson.Get,
+		"sensitive": sensitiveFunc(options.ShowSensitive),
+	}).Parse(raw)
+	if err != nil {
+		return "", err
+	}
 
-		args[i] = o.String()
+	o := new(bytes.Buffer)
+
+	if err := tpl.Execute(o, data); err != nil {
+		return "", err
 	}
 
-	return args, nil
+	return o.String(), nil
 }
 
 // ToCmd returns a Cmd object that can be used with the exec package.
@@ -84,34 +104,87 @@ func (c Command) ToCmd(ctx context.Context, data TemplateData) (*exec.Cmd, error
 	return exec.CommandContext(ctx, c.Name(), args...), nil
 }
 
+// argv returns the program followed by its arguments, rendered with sensitive values shown.
+func (c Command) argv(data TemplateData) ([]string, error) {
+	args, err := c.Args(data, TemplateOptions{
+		ShowSensitive: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{c.Name()}, args...), nil
+}
+
 // Display returns the command as a human readable string.
 func (c Command) Display(data TemplateData) (string, error) {
+	args, err := c.Args(data, TemplateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return c.display(args), nil
+}
+
+// Preview returns the command as a human readable string like Display, before the commands it depends on have run.
+// Arguments that cannot be rendered yet, e.g., referring to outputs of earlier commands, are shown as templates.
+func (c Command) Preview(data TemplateData) string {
+	args := make([]string, 0, len(c.Command)-1)
+
+	for _, raw := range c.Command[1:] {
+		arg, err := c.render(raw, data, TemplateOptions{})
+		if err != nil {
+			arg = raw
+		}
+
+		args = append(args, arg)
+	}
+
+	return c.display(args)
+}
+
+// display returns the command with the passed rendered arguments as a human readable string.
+func (c Command) display(args []string) string {
 	str := []string{}
 
 	if c.DisplayName != "" {
 		str = append(str, chalk.Cyan.Color(c.DisplayName))
 	}
 
+	command := append([]string{c.Name()}, args...)
+	str = append(str, chalk.Green.Color(strings.Join(command, " ")))
+
+	return strings.Join(str, ": ")
+}
+
+// plain returns the command as a string without colors, with sensitive values masked, or its program when its
+// arguments cannot be rendered.
+func (c Command) plain(data TemplateData) string {
 	args, err := c.Args(data, TemplateOptions{})
 	if err != nil {
-		return "", err
+		return c.Name()
 	}
 
-	command := append([]string{c.Name()}, args...)
-	str = append(str, chalk.Green.Color(strings.Join(command, " ")))
-
-	return strings.Join(str, ": "), nil
+	return strings.Join(append([]string{c.Name()}, args...), " ")
 }
 
-// Execute runs the command with the given config and outputs.
-func (c Command) Execute(ctx context.Context, config *Config, args Args, outputs Outputs, streams genericclioptions.IOStreams) ([]byte, error) {
-	data := TemplateData{
+// newTemplateData returns the template data for a command run with the given config, args and outputs.
+func newTemplateData(config *Config, args Args, outputs Outputs) TemplateData {
+	return TemplateData{
 		LocalPort: config.LocalPort,
+		ProxyAddr: config.ProxyAddr,
+		Targets:   config.Targets,
 		Args:      args,
 		Outputs:   outputs,
 	}
+}
+
+// Execute runs the command with the given config and outputs. When ctx is done, the command is asked to terminate and
+// is killed if it is still running after the configured grace period.
+func (c Command) Execute(ctx context.Context, config *Config, args Args, outputs Outputs, streams genericclioptions.IOStreams) ([]byte, error) {
+	data := newTemplateData(config, args, outputs)
 
-	cmd, err := c.ToCmd(ctx, data)
+	argv, err := c.argv(data)
 	if err != nil {
 		return nil, err
 	}
@@ -120,12 +193,14 @@ func (c Command) Execute(ctx context.Context, config *Config, args Args, outputs
 	fmt.Fprintf(streams.ErrOut, "> %s\n", cmdStr)
 
 	if c.Interactive {
-		cmd.Stdout = streams.Out
-		cmd.Stderr = streams.ErrOut
-		cmd.Stdin = streams.In
-
 		// interactive commands cannot return
-		return []byte{}, cmd.Run()
+		return []byte{}, config.runner().Run(ctx, Process{
+			Argv:        argv,
+			Stdin:       streams.In,
+			Stdout:      streams.Out,
+			Stderr:      streams.ErrOut,
+			Interactive: true,
+		})
 	}
 
 	outBuff := new(bytes.Buffer)
@@ -139,10 +214,11 @@ func (c Command) Execute(ctx context.Context, config *Config, args Args, outputs
 		ews = append(ews, streams.ErrOut)
 	}
 
-	cmd.Stdout = io.MultiWriter(ows...)
-	cmd.Stderr = io.MultiWriter(ews...)
-
-	if err := cmd.Run(); err != nil {
+	if err := config.runner().Run(ctx, Process{
+		Argv:   argv,
+		Stdout: io.MultiWriter(ows...),
+		Stderr: io.MultiWriter(ews...),
+	}); err != nil {
 		args, _ := c.Args(data, TemplateOptions{
 			ShowSensitive: false,
 		})
@@ -156,3 +232,40 @@ func (c Command) Execute(ctx context.Context, config *Config, args Args, outputs
 
 	return outBuff.Bytes(), nil
 }
+
+// runInteractive runs the command attached to the streams. When the streams are a terminal, the command is run in a
+// pseudo-terminal of its own, otherwise it is attached to the streams directly.
+func runInteractive(ctx context.Context, cmd *exec.Cmd, config *Config, streams genericclioptions.IOStreams) error {
+	if in, out, ok := terminal(streams); ok && ptySupported && !config.NoTTY {
+		return runPTY(ctx, cmd, in, out, config)
+	}
+
+	cmd.Stdout = streams.Out
+	cmd.Stderr = streams.ErrOut
+	cmd.Stdin = streams.In
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// The command shares the plugin's process group, so it receives terminal signals already and they are not forwarded.
+	config.Interrupts.attach(cmd.Process, false)
+	defer config.Interrupts.detach()
+
+	stop := terminateOnDone(ctx, cmd.Process, config.GracePeriod)
+	defer stop()
+
+	return cmd.Wait()
+}
+
+// run starts the command and waits for it to exit, terminating it once ctx is done.
+func run(ctx context.Context, cmd *exec.Cmd, config *Config) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	stop := terminateOnDone(ctx, cmd.Process, config.GracePeriod)
+	defer stop()
+
+	return cmd.Wait()
+}
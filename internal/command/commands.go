@@ -2,7 +2,11 @@ package command
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/takescoop/kubectl-exec-forward/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
@@ -10,9 +14,10 @@ import (
 type Commands []*Command
 
 // Execute runs each command in the calling slice sequentially using the passed config and the outputs accumulated to that point.
+// Commands with cache options reuse their cached output while it is still valid instead of being run.
 func (c Commands) Execute(ctx context.Context, config *Config, args Args, outputs Outputs, streams genericclioptions.IOStreams) (Outputs, error) {
 	for _, command := range c {
-		output, err := command.Execute(ctx, config, args, outputs, streams)
+		output, err := command.executeCached(ctx, config, args, outputs, streams)
 		if err != nil {
 			return nil, err
 		}
@@ -24,3 +29,46 @@ func (c Commands) Execute(ctx context.Context, config *Config, args Args, output
 
 	return outputs, nil
 }
+
+// executeCached runs the command, or returns its cached output when available, caching new outputs as configured. The
+// command is traced as a child of the span in ctx, with sensitive values masked.
+func (c Command) executeCached(ctx context.Context, config *Config, args Args, outputs Outputs, streams genericclioptions.IOStreams) (output []byte, err error) {
+	data := newTemplateData(config, args, outputs)
+
+	name := c.DisplayName
+	if name == "" {
+		name = c.Name()
+	}
+
+	ctx, span := tracing.Start(ctx, name, attribute.String("exec_forward.command", c.plain(data)))
+	defer func() { tracing.End(span, err) }()
+
+	key, cacheable, err := c.cacheKey(config, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		if output, ok := config.Cache.Get(key); ok {
+			cmdStr, _ := c.Display(data)
+			fmt.Fprintf(streams.ErrOut, "> %s (cached)\n", cmdStr)
+
+			span.SetAttributes(attribute.Bool("exec_forward.cached", true))
+
+			return output, nil
+		}
+	}
+
+	output, err = c.Execute(ctx, config, args, outputs, streams)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		if err := config.Cache.Set(key, output, time.Duration(c.Cache.TTL)); err != nil {
+			fmt.Fprintf(streams.ErrOut, "Unable to cache command output: %v\n", err)
+		}
+	}
+
+	return output, nil
+}
@@ -2,8 +2,11 @@ package command
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pborman/ansi"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -86,3 +89,143 @@ func TestCommandsExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandsExecuteFakeRunner(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		commands Commands
+		outputs  Outputs
+		runner   *FakeRunner
+
+		expected Outputs
+		argv     [][]string
+		error    bool
+	}{
+		{
+			name: "with outputs",
+			commands: Commands{
+				&Command{
+					ID:      "foo",
+					Command: []string{"echo", "hello"},
+				},
+				&Command{
+					ID:      "bar",
+					Command: []string{"rev", "{{ .Outputs.foo | trim }}"},
+				},
+			},
+			runner: NewFakeRunner().
+				Script(FakeResult{Stdout: "hello\n"}, "echo").
+				Script(FakeResult{Stdout: "olleh\n"}, "rev"),
+			expected: Outputs{"foo": "hello\n", "bar": "olleh\n"},
+			argv:     [][]string{{"echo", "hello"}, {"rev", "hello"}},
+		},
+		{
+			name: "no outputs",
+			commands: Commands{
+				&Command{
+					Command: []string{"echo", "hello"},
+				},
+			},
+			runner: NewFakeRunner().Script(FakeResult{Stdout: "hello\n"}, "echo"),
+			argv:   [][]string{{"echo", "hello"}},
+		},
+		{
+			name: "existing outputs",
+			outputs: Outputs{
+				"foo": "hello",
+			},
+			commands: Commands{
+				&Command{
+					ID:      "bar",
+					Command: []string{"echo", "{{ .Outputs.foo }}"},
+				},
+			},
+			runner:   NewFakeRunner().Script(FakeResult{Stdout: "hello\n"}, "echo", "hello"),
+			expected: Outputs{"foo": "hello", "bar": "hello\n"},
+			argv:     [][]string{{"echo", "hello"}},
+		},
+		{
+			name: "error",
+			commands: Commands{
+				&Command{
+					Command: []string{"false"},
+				},
+				&Command{
+					Command: []string{"echo", "hello"},
+				},
+			},
+			runner: NewFakeRunner().Script(FakeResult{ExitCode: 1}, "false"),
+			argv:   [][]string{{"false"}},
+			error:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			outputs, err := tc.commands.Execute(context.Background(), &Config{Runner: tc.runner}, Args{}, tc.outputs, genericclioptions.NewTestIOStreamsDiscard())
+
+			argv := [][]string{}
+			for _, run := range tc.runner.Runs() {
+				argv = append(argv, run.Argv)
+			}
+
+			assert.Equal(t, tc.argv, argv)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, outputs)
+		})
+	}
+}
+
+func TestCommandsExecuteCached(t *testing.T) {
+	t.Parallel()
+
+	runner := NewFakeRunner().Script(FakeResult{Stdout: "token\n"}, "generate-token")
+
+	commands := Commands{
+		&Command{
+			ID:      "token",
+			Command: []string{"generate-token", "{{.Args.username}}"},
+			Cache:   &CacheOptions{TTL: Duration(time.Minute), Key: "{{.Args.username}}"},
+		},
+	}
+
+	config := &Config{Cache: NewFileCache(t.TempDir()), CacheScope: "test", Runner: runner}
+
+	outputs, err := commands.Execute(context.Background(), config, Args{"username": "foo"}, Outputs{}, genericclioptions.NewTestIOStreamsDiscard())
+	require.NoError(t, err)
+	assert.Equal(t, Outputs{"token": "token\n"}, outputs)
+	assert.Len(t, runner.Runs(), 1)
+
+	streams, _, _, stderr := genericclioptions.NewTestIOStreams()
+
+	outputs, err = commands.Execute(context.Background(), config, Args{"username": "foo"}, Outputs{}, streams)
+	require.NoError(t, err)
+	assert.Equal(t, Outputs{"token": "token\n"}, outputs)
+	assert.Len(t, runner.Runs(), 1, "cached output was not reused")
+
+	plainStderr, err := ansi.Strip(stderr.Bytes())
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(plainStderr), "(cached)\n"), "cached output was not reported")
+
+	_, err = commands.Execute(context.Background(), config, Args{"username": "bar"}, Outputs{}, genericclioptions.NewTestIOStreamsDiscard())
+	require.NoError(t, err)
+	assert.Len(t, runner.Runs(), 2, "output cached for another key was reused")
+
+	_, err = commands.Execute(context.Background(), &Config{Runner: runner}, Args{"username": "foo"}, Outputs{}, genericclioptions.NewTestIOStreamsDiscard())
+	require.NoError(t, err)
+	assert.Len(t, runner.Runs(), 3, "output was cached without a cache")
+}
@@ -1,7 +1,36 @@
 package command
 
+import (
+	"time"
+)
+
 // Config stores configuration for executing commands.
 type Config struct {
 	LocalPort int
-	Verbose   bool
+	// ProxyAddr is the address of the local SOCKS5 proxy of proxy sessions. It is empty for other sessions.
+	ProxyAddr string
+	// Targets are the targets of multi-target sessions by name, including the target LocalPort is forwarded to.
+	Targets map[string]Target
+	Verbose bool
+	// Cache stores the outputs of commands with cache options. When nil, outputs are never cached.
+	Cache Cache
+	// CacheScope distinguishes cached outputs between forwarding targets, e.g., by cluster, namespace and pod owner.
+	CacheScope string
+	// NoTTY attaches interactive commands to the streams directly, rather than to a pseudo-terminal.
+	NoTTY bool
+	// Interrupts routes interrupt signals received while an interactive command runs to that command.
+	Interrupts *Interrupts
+	// GracePeriod is how long commands are given to exit after being asked to terminate, before they are killed.
+	GracePeriod time.Duration
+	// Runner runs the processes of commands. When nil, processes are run on the local machine.
+	Runner Runner
+}
+
+// runner returns the configured runner, or the default runner running processes on the local machine.
+func (c *Config) runner() Runner {
+	if c.Runner != nil {
+		return c.Runner
+	}
+
+	return execRunner{config: c}
 }
@@ -0,0 +1,148 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FakeResult is the scripted result of a process run by a FakeRunner.
+type FakeResult struct {
+	Stdout string
+	Stderr string
+	// ExitCode fails the process with a FakeExitError when not zero.
+	ExitCode int
+	// Err is returned by Run when not nil, e.g., to simulate a program that is not installed.
+	Err error
+}
+
+// FakeRun is a process recorded by a FakeRunner.
+type FakeRun struct {
+	Argv        []string
+	Stdin       string
+	Interactive bool
+}
+
+// FakeExitError is returned by a FakeRunner for processes scripted with a non-zero exit code.
+type FakeExitError struct {
+	Code int
+}
+
+// Error returns the exit status, formatted like the exec package does.
+func (e *FakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the scripted exit code.
+func (e *FakeExitError) ExitCode() int {
+	return e.Code
+}
+
+// fakeScript is a result replied to processes starting with a prefix.
+type fakeScript struct {
+	prefix []string
+	result FakeResult
+}
+
+// FakeRunner is a Runner that records processes instead of running them and replies with scripted results, so that
+// commands can be tested without running any program. Processes without a script succeed without output.
+type FakeRunner struct {
+	mu      sync.Mutex
+	scripts []fakeScript
+	runs    []FakeRun
+}
+
+// NewFakeRunner returns a runner without scripted results.
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{}
+}
+
+// Script replies with result to processes whose arguments start with prefix, e.g., the program's name. Scripts are
+// matched in the order they were added.
+func (r *FakeRunner) Script(result FakeResult, prefix ...string) *FakeRunner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.scripts = append(r.scripts, fakeScript{prefix: prefix, result: result})
+
+	return r
+}
+
+// Run records the process, reading its input to the end, and writes the scripted output.
+func (r *FakeRunner) Run(_ context.Context, p Process) error {
+	run := FakeRun{
+		Argv:        append([]string{}, p.Argv...),
+		Interactive: p.Interactive,
+	}
+
+	if p.Stdin != nil {
+		b, err := io.ReadAll(p.Stdin)
+		if err != nil {
+			return err
+		}
+
+		run.Stdin = string(b)
+	}
+
+	r.mu.Lock()
+	r.runs = append(r.runs, run)
+	result := r.result(p.Argv)
+	r.mu.Unlock()
+
+	if p.Stdout != nil {
+		if _, err := io.WriteString(p.Stdout, result.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if p.Stderr != nil {
+		if _, err := io.WriteString(p.Stderr, result.Stderr); err != nil {
+			return err
+		}
+	}
+
+	if result.Err != nil {
+		return result.Err
+	}
+
+	if result.ExitCode != 0 {
+		return &FakeExitError{Code: result.ExitCode}
+	}
+
+	return nil
+}
+
+// Runs returns the processes run so far, in order.
+func (r *FakeRunner) Runs() []FakeRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]FakeRun{}, r.runs...)
+}
+
+// result returns the result of the first script matching argv.
+func (r *FakeRunner) result(argv []string) FakeResult {
+	for _, s := range r.scripts {
+		if hasPrefix(argv, s.prefix) {
+			return s.result
+		}
+	}
+
+	return FakeResult{}
+}
+
+// hasPrefix returns whether s starts with prefix.
+func hasPrefix(s []string, prefix []string) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,87 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestFakeRunner(t *testing.T) {
+	t.Parallel()
+
+	errNotFound := errors.New("executable file not found")
+
+	cases := []struct {
+		name string
+
+		runner  *FakeRunner
+		process Process
+
+		stdout string
+		stderr string
+		run    FakeRun
+		err    error
+	}{
+		{
+			name:    "record the process",
+			runner:  NewFakeRunner(),
+			process: Process{Argv: []string{"psql", "-h", "localhost"}, Stdin: strings.NewReader("select 1;"), Interactive: true},
+			run:     FakeRun{Argv: []string{"psql", "-h", "localhost"}, Stdin: "select 1;", Interactive: true},
+		},
+		{
+			name: "reply with the first matching script",
+			runner: NewFakeRunner().
+				Script(FakeResult{Stdout: "other"}, "aws", "sts").
+				Script(FakeResult{Stdout: "token", Stderr: "warning"}, "aws", "rds").
+				Script(FakeResult{Stdout: "any"}, "aws"),
+			process: Process{Argv: []string{"aws", "rds", "generate-db-auth-token"}},
+			stdout:  "token",
+			stderr:  "warning",
+			run:     FakeRun{Argv: []string{"aws", "rds", "generate-db-auth-token"}},
+		},
+		{
+			name:    "fail with an exit code",
+			runner:  NewFakeRunner().Script(FakeResult{ExitCode: 2}, "false"),
+			process: Process{Argv: []string{"false"}},
+			run:     FakeRun{Argv: []string{"false"}},
+			err:     &FakeExitError{Code: 2},
+		},
+		{
+			name:    "fail with an error",
+			runner:  NewFakeRunner().Script(FakeResult{Err: errNotFound}, "psql"),
+			process: Process{Argv: []string{"psql"}},
+			run:     FakeRun{Argv: []string{"psql"}},
+			err:     errNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, stdout, stderr := genericclioptions.NewTestIOStreams()
+
+			tc.process.Stdout = stdout
+			tc.process.Stderr = stderr
+
+			err := tc.runner.Run(context.Background(), tc.process)
+
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.stdout, stdout.String())
+			assert.Equal(t, tc.stderr, stderr.String())
+			assert.Equal(t, []FakeRun{tc.run}, tc.runner.Runs())
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"os"
+	"sync"
+)
+
+// Interrupts routes interrupt signals received by the plugin while an interactive command runs. The signal is meant
+// for the command, e.g., to cancel a running query, so the plugin ignores it rather than closing the connection.
+type Interrupts struct {
+	mu      sync.Mutex
+	process *os.Process
+	forward bool
+}
+
+// NewInterrupts returns an interrupt router without a running command.
+func NewInterrupts() *Interrupts {
+	return &Interrupts{}
+}
+
+// attach registers the running interactive command. When forward is set, signals are sent on to the command, which is
+// needed when it does not share the plugin's process group, e.g., when it runs in its own terminal.
+func (i *Interrupts) attach(process *os.Process, forward bool) {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.process = process
+	i.forward = forward
+}
+
+// detach unregisters the interactive command once it has exited.
+func (i *Interrupts) detach() {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.process = nil
+	i.forward = false
+}
+
+// Handle routes the signal to the running interactive command, if any, and returns whether it did. When it returns
+// false, the signal is meant for the plugin itself.
+func (i *Interrupts) Handle(sig os.Signal) bool {
+	if i == nil {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.process == nil {
+		return false
+	}
+
+	if i.forward {
+		_ = i.process.Signal(sig)
+	}
+
+	return true
+}
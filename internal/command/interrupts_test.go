@@ -0,0 +1,59 @@
+package command
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterruptsHandle(t *testing.T) {
+	t.Run("no running command", func(t *testing.T) {
+		assert.False(t, NewInterrupts().Handle(os.Interrupt))
+	})
+
+	t.Run("nil interrupts", func(t *testing.T) {
+		var interrupts *Interrupts
+
+		interrupts.attach(nil, false)
+		assert.False(t, interrupts.Handle(os.Interrupt))
+	})
+
+	t.Run("running command", func(t *testing.T) {
+		cmd := exec.Command("sleep", "10")
+		require.NoError(t, cmd.Start())
+
+		interrupts := NewInterrupts()
+		interrupts.attach(cmd.Process, false)
+
+		assert.True(t, interrupts.Handle(os.Interrupt))
+
+		interrupts.detach()
+		assert.False(t, interrupts.Handle(os.Interrupt))
+
+		require.NoError(t, cmd.Process.Kill())
+		_ = cmd.Wait()
+	})
+
+	t.Run("forward to running command", func(t *testing.T) {
+		cmd := exec.Command("sleep", "10")
+		require.NoError(t, cmd.Start())
+
+		interrupts := NewInterrupts()
+		interrupts.attach(cmd.Process, true)
+
+		assert.True(t, interrupts.Handle(syscall.SIGTERM))
+
+		err := cmd.Wait()
+
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, err, &exitErr)
+
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		require.True(t, ok)
+		assert.Equal(t, syscall.SIGTERM, status.Signal())
+	})
+}
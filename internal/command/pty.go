@@ -0,0 +1,165 @@
+//go:build !windows
+
+package command
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// ptySupported indicates whether interactive commands can be run in a pseudo-terminal on this platform.
+const ptySupported = true
+
+// runPTY runs the command in a new pseudo-terminal connected to the passed terminal, which is put in raw mode for the
+// duration of the command. Keys such as Ctrl-C are therefore handled by the command's terminal and only signal the
+// command, and window size changes are propagated to the pseudo-terminal.
+func runPTY(ctx context.Context, cmd *exec.Cmd, in *os.File, out *os.File, config *Config) error {
+	size, err := pty.GetsizeFull(in)
+	if err != nil {
+		return err
+	}
+
+	ptmx, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return err
+	}
+
+	defer ptmx.Close()
+
+	// The command runs in its own session, so signals received by the plugin are forwarded to it.
+	config.Interrupts.attach(cmd.Process, true)
+	defer config.Interrupts.detach()
+
+	stop := terminateOnDone(ctx, cmd.Process, config.GracePeriod)
+	defer stop()
+
+	resize := make(chan os.Signal, 1)
+	resizeDone := make(chan struct{})
+
+	signal.Notify(resize, syscall.SIGWINCH)
+
+	// The pseudo-terminal must not be resized once it is closed, so resizing stops before it is.
+	defer func() {
+		signal.Stop(resize)
+		close(resize)
+		<-resizeDone
+	}()
+
+	go func() {
+		defer close(resizeDone)
+
+		for range resize {
+			_ = pty.InheritSize(in, ptmx)
+		}
+	}()
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+
+		return err
+	}
+
+	defer func() {
+		_ = term.Restore(int(in.Fd()), state)
+	}()
+
+	// Input is only read while the command runs, so that it is left to later readers of the terminal once it exits.
+	stopInput, err := copyInput(ptmx, in)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+
+		return err
+	}
+
+	defer stopInput()
+
+	// Reading from the pseudo-terminal fails once the command exits and its side of the terminal is closed.
+	if _, err := io.Copy(out, ptmx); err != nil && !errors.Is(err, syscall.EIO) {
+		_ = cmd.Wait()
+
+		return err
+	}
+
+	return cmd.Wait()
+}
+
+// inputBufferSize is the size of the reads of the terminal's input.
+const inputBufferSize = 32 * 1024
+
+// copyInput copies the terminal's input to dst until the returned function is called, which waits for copying to stop.
+// The terminal is only read once input is available, so that no input is consumed after copying stopped.
+func copyInput(dst io.Writer, in *os.File) (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer r.Close()
+
+		inFd, stopFd := int(in.Fd()), int(r.Fd())
+		buf := make([]byte, inputBufferSize)
+
+		for {
+			fds := &unix.FdSet{}
+			fds.Set(inFd)
+			fds.Set(stopFd)
+
+			nfd := inFd
+			if stopFd > nfd {
+				nfd = stopFd
+			}
+
+			if _, err := unix.Select(nfd+1, fds, nil, nil, nil); err != nil {
+				if errors.Is(err, unix.EINTR) {
+					continue
+				}
+
+				return
+			}
+
+			if fds.IsSet(stopFd) {
+				return
+			}
+
+			if !fds.IsSet(inFd) {
+				continue
+			}
+
+			n, err := unix.Read(inFd, buf)
+			if n > 0 {
+				if _, err := dst.Write(buf[:n]); err != nil {
+					return
+				}
+			}
+
+			if errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN) {
+				continue
+			}
+
+			if err != nil || n == 0 {
+				return
+			}
+		}
+	}()
+
+	return func() {
+		w.Close()
+		<-done
+	}, nil
+}
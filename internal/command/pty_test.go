@@ -0,0 +1,134 @@
+//go:build !windows
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// openTerminal returns streams attached to a new pseudo-terminal along with its controlling side, which acts as the
+// user's terminal emulator.
+func openTerminal(t *testing.T) (genericclioptions.IOStreams, *os.File) {
+	t.Helper()
+
+	ptmx, tty, err := pty.Open()
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		ptmx.Close()
+		tty.Close()
+	})
+
+	require.NoError(t, pty.Setsize(tty, &pty.Winsize{Rows: 40, Cols: 120}))
+
+	return genericclioptions.IOStreams{In: tty, Out: tty, ErrOut: io.Discard}, ptmx
+}
+
+func TestCommandExecuteInteractivePTY(t *testing.T) {
+	t.Run("run in a pseudo-terminal with the terminal's size", func(t *testing.T) {
+		streams, ptmx := openTerminal(t)
+
+		output := &safeBuffer{}
+
+		go func() {
+			_, _ = io.Copy(output, ptmx)
+		}()
+
+		command := Command{
+			Command:     []string{"sh", "-c", "test -t 0 && test -t 1 && stty size"},
+			Interactive: true,
+		}
+
+		_, err := command.Execute(context.Background(), &Config{}, Args{}, Outputs{}, streams)
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			return strings.Contains(output.String(), "40 120")
+		}, 5*time.Second, 10*time.Millisecond, "terminal size was not propagated: %q", output.String())
+	})
+
+	t.Run("attach to the streams without a pseudo-terminal", func(t *testing.T) {
+		streams, ptmx := openTerminal(t)
+
+		stdout := &bytes.Buffer{}
+		streams.Out = stdout
+
+		command := Command{
+			Command:     []string{"sh", "-c", "test -t 1 || echo no tty"},
+			Interactive: true,
+		}
+
+		_, err := command.Execute(context.Background(), &Config{NoTTY: true}, Args{}, Outputs{}, streams)
+		require.NoError(t, err)
+
+		assert.Equal(t, "no tty\n", stdout.String())
+		assert.NoError(t, ptmx.Close())
+	})
+}
+
+func TestCommandExecuteInteractivePTYInput(t *testing.T) {
+	streams, ptmx := openTerminal(t)
+
+	go func() {
+		_, _ = io.Copy(io.Discard, ptmx)
+	}()
+
+	command := Command{
+		Command:     []string{"true"},
+		Interactive: true,
+	}
+
+	_, err := command.Execute(context.Background(), &Config{}, Args{}, Outputs{}, streams)
+	require.NoError(t, err)
+
+	// Input typed once the command has exited is left to the next reader, e.g., a confirmation prompt.
+	_, err = ptmx.Write([]byte("yes\n"))
+	require.NoError(t, err)
+
+	lineChan := make(chan string, 1)
+
+	go func() {
+		b := make([]byte, 16)
+		n, _ := streams.In.Read(b)
+		lineChan <- string(b[:n])
+	}()
+
+	select {
+	case line := <-lineChan:
+		assert.Equal(t, "yes\n", line)
+	case <-time.After(5 * time.Second):
+		t.Fatal("input was consumed after the command exited")
+	}
+}
+
+// safeBuffer is a buffer that can be written and read concurrently.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
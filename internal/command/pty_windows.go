@@ -0,0 +1,16 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// ptySupported indicates whether interactive commands can be run in a pseudo-terminal on this platform.
+const ptySupported = false
+
+// runPTY is not supported on Windows, interactive commands are attached to the console directly.
+func runPTY(_ context.Context, _ *exec.Cmd, _ *os.File, _ *os.File, _ *Config) error {
+	return errors.New("pseudo-terminals are not supported on windows")
+}
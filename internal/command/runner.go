@@ -0,0 +1,47 @@
+package command
+
+import (
+	"context"
+	"io"
+	"os/exec"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Process is a single run of a command, with its arguments rendered.
+type Process struct {
+	// Argv is the program followed by its arguments.
+	Argv   []string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Interactive indicates that the process is attached to the user's streams, which may be a terminal.
+	Interactive bool
+}
+
+// Runner runs the processes of commands. Run returns once the process has exited, and terminates it once ctx is done.
+type Runner interface {
+	Run(ctx context.Context, p Process) error
+}
+
+// execRunner is the default Runner, running processes on the local machine.
+type execRunner struct {
+	config *Config
+}
+
+// Run runs the process with the exec package. Interactive processes are run in a pseudo-terminal when attached to a
+// terminal.
+func (r execRunner) Run(ctx context.Context, p Process) error {
+	//nolint:gosec
+	cmd := exec.Command(p.Argv[0], p.Argv[1:]...)
+
+	if p.Interactive {
+		return runInteractive(ctx, cmd, r.config, genericclioptions.IOStreams{In: p.Stdin, Out: p.Stdout, ErrOut: p.Stderr})
+	}
+
+	cmd.Stdin = p.Stdin
+	cmd.Stdout = p.Stdout
+	cmd.Stderr = p.Stderr
+
+	return run(ctx, cmd, r.config)
+}
@@ -0,0 +1,20 @@
+package command
+
+import (
+	"os"
+
+	"golang.org/x/term"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// terminal returns the terminal attached to the streams' input and output, if both are terminals.
+func terminal(streams genericclioptions.IOStreams) (in *os.File, out *os.File, ok bool) {
+	in, inOK := streams.In.(*os.File)
+	out, outOK := streams.Out.(*os.File)
+
+	if !inOK || !outOK || !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return nil, nil, false
+	}
+
+	return in, out, true
+}
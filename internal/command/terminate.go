@@ -0,0 +1,36 @@
+package command
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// terminateOnDone asks the process to terminate once ctx is done, and kills it when it is still running after the
+// grace period. The returned function stops watching the process and must be called once it has exited.
+func terminateOnDone(ctx context.Context, process *os.Process, gracePeriod time.Duration) func() {
+	exited := make(chan struct{})
+
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-ctx.Done():
+		}
+
+		_ = terminate(process)
+
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+
+		select {
+		case <-exited:
+		case <-timer.C:
+			_ = process.Kill()
+		}
+	}()
+
+	return func() {
+		close(exited)
+	}
+}
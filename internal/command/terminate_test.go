@@ -0,0 +1,73 @@
+//go:build !windows
+
+package command
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestCommandExecuteTerminate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		script      string
+		gracePeriod time.Duration
+
+		output string
+	}{
+		{
+			name:        "terminate the command",
+			script:      `trap 'echo terminated; exit 0' TERM; echo started; while true; do sleep 0.01; done`,
+			gracePeriod: 10 * time.Second,
+			output:      "started\nterminated\n",
+		},
+		{
+			name:        "kill the command after the grace period",
+			script:      `trap '' TERM; echo started; while true; do sleep 0.01; done`,
+			gracePeriod: 100 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			out := &safeBuffer{}
+			streams := genericclioptions.IOStreams{Out: out, ErrOut: io.Discard}
+
+			go func() {
+				assert.Eventually(t, func() bool { return out.String() != "" }, 5*time.Second, 10*time.Millisecond)
+				cancel()
+			}()
+
+			command := Command{Command: []string{"sh", "-c", tc.script}}
+			start := time.Now()
+
+			output, err := command.Execute(ctx, &Config{Verbose: true, GracePeriod: tc.gracePeriod}, Args{}, Outputs{}, streams)
+
+			assert.Less(t, time.Since(start), 5*time.Second)
+
+			if tc.output == "" {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.output, string(output))
+		})
+	}
+}
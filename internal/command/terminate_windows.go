@@ -0,0 +1,10 @@
+package command
+
+import (
+	"os"
+)
+
+// terminate asks the process to exit. Windows processes cannot be signaled, so they are killed.
+func terminate(process *os.Process) error {
+	return process.Kill()
+}
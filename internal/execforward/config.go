@@ -1,9 +1,90 @@
 package execforward
 
+import (
+	"io"
+	"time"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+	"github.com/takescoop/kubectl-exec-forward/internal/proxy"
+	"github.com/takescoop/kubectl-exec-forward/internal/trust"
+)
+
 // Config stores configuration which is used to construct the tunnel as well as passed to the hook commands.
 type Config struct {
 	LocalPort int
 	Verbose   bool
 	Command   []string
 	Persist   bool
+	Container string
+	// Trust is the policy deciding whether annotation commands may run. When nil, commands are run without checks.
+	Trust *trust.Policy
+	// AssumeYes runs commands not trusted by the policy without asking for confirmation.
+	AssumeYes bool
+	// Cache stores the outputs of commands with cache options across invocations. When nil, outputs are not cached.
+	Cache command.Cache
+	// NoTTY runs the main command detached from the terminal, reading its input from Stdin and writing its output to
+	// Stdout, e.g., for scripts and CI jobs.
+	NoTTY bool
+	// Stdin is the main command's input when NoTTY is set. When nil, the main command receives no input.
+	Stdin io.Reader
+	// Stdout is the main command's output when NoTTY is set. When nil, the output is written to the output stream.
+	Stdout io.Writer
+	// OpenStdout opens the main command's output when NoTTY is set, right before the main command starts, so that a
+	// session failing earlier does not truncate an output file. It takes precedence over Stdout.
+	OpenStdout func() (io.Writer, error)
+	// Interrupts routes interrupt signals received while an interactive command runs to that command.
+	Interrupts *command.Interrupts
+	// GracePeriod bounds shutdown: running commands are killed when they have not exited this long after being asked to
+	// terminate, and teardown commands and releasing the targets must complete within the same period.
+	GracePeriod time.Duration
+	// Runner runs the processes of commands. When nil, processes are run on the local machine.
+	Runner command.Runner
+	// Events receives notifications about the progress of the session. When nil, notifications are ignored.
+	Events Events
+	// Proxy serves local proxies dialing connections through the forwarded port, which must be a SOCKS5 server such as
+	// a relay pod. When nil, no proxy is served.
+	Proxy *proxy.Config
+	// Ephemeral adds an ephemeral container to the forwarded pod before any command runs. When nil, the pod is left
+	// unchanged.
+	Ephemeral *forwarder.EphemeralContainer
+	// Wake scales Deployments and StatefulSets without replicas up before forwarding to them, restoring their replicas
+	// once the session ends.
+	Wake bool
+	// WakeTimeout is how long to wait for a replica of a woken up workload to be ready.
+	WakeTimeout time.Duration
+	// SpawnTimeout is how long to wait for the pod of a Job spawned from a CronJob to be attachable. When zero,
+	// forwarder.DefaultSpawnTimeout is used.
+	SpawnTimeout time.Duration
+	// JobTTL is how long Jobs spawned from CronJobs may run before Kubernetes stops them, should the session end without
+	// deleting them. When zero, forwarder.DefaultJobTTL is used.
+	JobTTL time.Duration
+	// Name identifies the resource in the Targets of command templates. It defaults to the name of the resource.
+	Name string
+	// Targets are forwarded to along with the resource, sharing the commands found on the resource's pod.
+	Targets []Target
+	// Annotations override the command annotations of the resource's pod, e.g., with those declared by a session spec
+	// file, see annotation.Merge.
+	Annotations map[string]string
+	// ClientFor returns the client forwarding to targets found in another kubeconfig context or namespace, where an
+	// empty context is the session's. When nil, such targets are refused.
+	ClientFor func(context string, namespace string) (*forwarder.Client, error)
+	// MetricsAddr is the address the metrics of the forwarding connections are served on in the Prometheus text format,
+	// under /metrics. When empty, metrics are not served.
+	MetricsAddr string
+	// IdleTimeout closes the session once no traffic has gone through its forwarded ports for this long. When zero, the
+	// session is only closed for being idle when the pod's annotations set an idle timeout.
+	IdleTimeout time.Duration
+	// MaxDuration closes the session once it has been connected for this long. When zero, the session only has a
+	// maximum duration when the pod's annotations set one.
+	MaxDuration time.Duration
+}
+
+// events returns the configured events, or events ignoring every notification.
+func (c *Config) events() Events {
+	if c.Events != nil {
+		return c.Events
+	}
+
+	return NopEvents{}
 }
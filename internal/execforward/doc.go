@@ -1,4 +1,11 @@
 // Package execforward is the main entrypoint that provides the high level
 // functionality used by the exec-forward CLI. It defines the lifecycle of a
 // forwarding connection, including "pre" and "post" connect hooks.
+//
+// Start opens a Session, which runs the remaining lifecycle in the background and notifies the configured Events of its
+// progress. Run is the blocking form used by the CLI.
+//
+// Errors returned by Run are classified by the stage that failed, and map to the exit code of the plugin through
+// ExitCode. When the main command fails, its own exit code is used, so that scripts wrapping the plugin can act on the
+// command's status. That status may collide with the plugin's own exit codes, which PluginFailure tells apart.
 package execforward
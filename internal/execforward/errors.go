@@ -0,0 +1,127 @@
+package execforward
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// Exit codes returned for failures of the plugin itself. They are kept at the top of the exit code range, away from
+// the codes commands commonly exit with, but the main command's exit code is returned as is and may fall in the same
+// range, e.g., a script exiting with 250. PluginFailure tells the two apart.
+const (
+	// ExitCodeExpired is returned when the session is closed for having been idle for its idle timeout, or for having
+	// lasted its maximum duration.
+	ExitCodeExpired = 249
+	// ExitCodeError is returned for errors not covered by a more specific exit code, e.g., invalid flags.
+	ExitCodeError = 250
+	// ExitCodeConfig is returned when the target cannot be resolved or its annotations are invalid.
+	ExitCodeConfig = 251
+	// ExitCodeDenied is returned when the user lacks permissions or the commands are not trusted.
+	ExitCodeDenied = 252
+	// ExitCodeHook is returned when a pre-connect, post-connect or teardown command fails.
+	ExitCodeHook = 253
+	// ExitCodeTunnel is returned when the port-forwarding connection cannot be established or is lost.
+	ExitCodeTunnel = 254
+	// ExitCodeCommandNotRun is returned when the main command cannot be started, e.g., it is not installed.
+	ExitCodeCommandNotRun = 127
+)
+
+// Error is a failure of the forwarding lifecycle, carrying the exit code the plugin exits with.
+type Error struct {
+	Code int
+	Err  error
+
+	// command is set when Code is the main command's exit code rather than one of the plugin's.
+	command bool
+}
+
+// Error returns the underlying error message.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError wraps err with the passed exit code. A nil err returns nil.
+func newError(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Code: code, Err: err}
+}
+
+// exitCoder is an error of a process that ran and exited unsuccessfully, such as exec.ExitError or
+// command.FakeExitError.
+type exitCoder interface {
+	error
+	ExitCode() int
+}
+
+// newCommandError wraps an error returned by the main command, using the command's own exit code. Commands killed by
+// a signal exit with 128 plus the signal number, as in a shell.
+func newCommandError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal())
+		}
+
+		return &Error{Code: code, Err: err, command: true}
+	}
+
+	var coder exitCoder
+	if errors.As(err, &coder) {
+		return &Error{Code: coder.ExitCode(), Err: err, command: true}
+	}
+
+	return &Error{Code: ExitCodeCommandNotRun, Err: err, command: true}
+}
+
+// ExitCode returns the exit code the plugin exits with for the passed error: 0 without an error, the main command's
+// exit code when it failed, one of the ExitCode constants for failures of the plugin and ExitCodeError otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return ExitCodeError
+}
+
+// PluginFailure returns whether the error is a failure of the plugin itself, as opposed to the main command exiting
+// unsuccessfully or not being found, whose exit codes may be the same as the plugin's.
+func PluginFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return !e.command
+	}
+
+	return true
+}
+
+// Reported returns whether the error is a command exiting unsuccessfully. Commands report their own failures, so such
+// errors do not need to be printed again.
+func Reported(err error) bool {
+	var coder exitCoder
+
+	return errors.As(err, &coder)
+}
@@ -0,0 +1,92 @@
+package execforward
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	runErr := func(name string, args ...string) error {
+		return exec.Command(name, args...).Run()
+	}
+
+	cases := []struct {
+		name     string
+		err      error
+		expected int
+		reported bool
+		plugin   bool
+	}{
+		{
+			name:     "success",
+			expected: 0,
+		},
+		{
+			name:     "plugin error",
+			err:      errors.New("unknown flag"),
+			expected: ExitCodeError,
+			plugin:   true,
+		},
+		{
+			name:     "classified error",
+			err:      fmt.Errorf("wrapped: %w", newError(ExitCodeTunnel, errors.New("lost connection to pod"))),
+			expected: ExitCodeTunnel,
+			plugin:   true,
+		},
+		{
+			name:     "hook failure",
+			err:      newError(ExitCodeHook, runErr("false")),
+			expected: ExitCodeHook,
+			reported: true,
+			plugin:   true,
+		},
+		{
+			name:     "main command exit code",
+			err:      newCommandError(runErr("sh", "-c", "exit 3")),
+			expected: 3,
+			reported: true,
+		},
+		{
+			name:     "main command killed by a signal",
+			err:      newCommandError(runErr("sh", "-c", "kill -TERM $$")),
+			expected: 143,
+			reported: true,
+		},
+		{
+			name:     "fake main command exit code",
+			err:      newCommandError(&command.FakeExitError{Code: 2}),
+			expected: 2,
+			reported: true,
+		},
+		{
+			name:     "main command exit code in the plugin range",
+			err:      newCommandError(runErr("sh", "-c", "exit 250")),
+			expected: ExitCodeError,
+			reported: true,
+		},
+		{
+			name:     "main command not found",
+			err:      newCommandError(runErr("exec-forward-command-not-found")),
+			expected: ExitCodeCommandNotRun,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, ExitCode(tc.err))
+			assert.Equal(t, tc.reported, Reported(tc.err))
+			assert.Equal(t, tc.plugin, PluginFailure(tc.err))
+		})
+	}
+}
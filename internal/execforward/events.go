@@ -0,0 +1,41 @@
+package execforward
+
+import (
+	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+)
+
+// Stages of the forwarding lifecycle, in execution order.
+const (
+	StagePreConnect  = "pre-connect"
+	StagePostConnect = "post-connect"
+	StageCommand     = "command"
+	StageTeardown    = "teardown"
+)
+
+// Events receives notifications about the progress of a session. Methods are called from the session's goroutines and
+// should return quickly.
+type Events interface {
+	// StageStarted is called before the commands of a lifecycle stage run. Stages without commands are skipped.
+	StageStarted(stage string)
+	// StageFinished is called once the commands of a stage have run, with the error of the failing command, if any.
+	StageFinished(stage string, err error)
+	// Connected is called once the forwarding connection is established.
+	Connected(ports []forwarder.Connection)
+	// Closed is called once the session has ended, with the error returned by Wait.
+	Closed(err error)
+}
+
+// NopEvents ignores every notification. It can be embedded by implementations only interested in some events.
+type NopEvents struct{}
+
+// StageStarted does nothing.
+func (NopEvents) StageStarted(string) {}
+
+// StageFinished does nothing.
+func (NopEvents) StageFinished(string, error) {}
+
+// Connected does nothing.
+func (NopEvents) Connected([]forwarder.Connection) {}
+
+// Closed does nothing.
+func (NopEvents) Closed(error) {}
@@ -3,13 +3,15 @@ package execforward
 import (
 	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
 	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"github.com/takescoop/kubectl-exec-forward/internal/trust"
 )
 
 // Hooks store information regarding command hooks.
 type Hooks struct {
-	Pre     command.Commands
-	Post    command.Commands
-	Command command.Command
+	Pre      command.Commands
+	Post     command.Commands
+	Command  command.Command
+	Teardown command.Commands
 }
 
 // newHooks returns a new Hooks struct assembled from the passed annotations.
@@ -24,9 +26,15 @@ func newHooks(annotations map[string]string, config *Config) (*Hooks, error) {
 		return nil, err
 	}
 
+	teardown, err := annotation.ParseCommands(annotations, annotation.Teardown)
+	if err != nil {
+		return nil, err
+	}
+
 	hooks := &Hooks{
-		Pre:  pre,
-		Post: post,
+		Pre:      pre,
+		Post:     post,
+		Teardown: teardown,
 	}
 
 	c, err := annotation.ParseCommand(annotations)
@@ -46,3 +54,35 @@ func newHooks(annotations map[string]string, config *Config) (*Hooks, error) {
 
 	return hooks, nil
 }
+
+// steps returns every command of the lifecycle labeled with its stage, in execution order.
+func (h *Hooks) steps() []trust.Step {
+	steps := []trust.Step{}
+
+	for _, c := range h.Pre {
+		steps = append(steps, trust.Step{Stage: StagePreConnect, Command: *c})
+	}
+
+	for _, c := range h.Post {
+		steps = append(steps, trust.Step{Stage: StagePostConnect, Command: *c})
+	}
+
+	if len(h.Command.Command) > 0 {
+		steps = append(steps, trust.Step{Stage: StageCommand, Command: h.Command})
+	}
+
+	for _, c := range h.Teardown {
+		steps = append(steps, trust.Step{Stage: StageTeardown, Command: *c})
+	}
+
+	return steps
+}
+
+// main returns the main command as a list of commands to run, empty when there is no main command.
+func (h *Hooks) main() command.Commands {
+	if len(h.Command.Command) == 0 {
+		return command.Commands{}
+	}
+
+	return command.Commands{&h.Command}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
 	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"github.com/takescoop/kubectl-exec-forward/internal/trust"
 )
 
 func TestNewHooks(t *testing.T) {
@@ -34,6 +35,15 @@ func TestNewHooks(t *testing.T) {
 		assert.Equal(t, command.Commands{{Command: []string{"echo", "hello"}}}, actual.Post)
 	})
 
+	t.Run("return hooks with teardown commands", func(t *testing.T) {
+		actual, err := newHooks(map[string]string{
+			annotation.Teardown: `[{"command": ["echo", "hello"]}]`,
+		}, nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, command.Commands{{Command: []string{"echo", "hello"}}}, actual.Teardown)
+	})
+
 	t.Run("return hooks with a main command", func(t *testing.T) {
 		actual, err := newHooks(map[string]string{
 			annotation.Command: `{"command": ["echo", "hello"]}`,
@@ -65,3 +75,29 @@ func TestNewHooks(t *testing.T) {
 		}, actual)
 	})
 }
+
+func TestHooksSteps(t *testing.T) {
+	t.Run("return steps in execution order", func(t *testing.T) {
+		hooks, err := newHooks(map[string]string{
+			annotation.PreConnect:  `[{"command": ["echo", "pre"]}]`,
+			annotation.PostConnect: `[{"command": ["echo", "post"]}]`,
+			annotation.Command:     `{"command": ["echo", "main"]}`,
+			annotation.Teardown:    `[{"command": ["echo", "teardown"]}]`,
+		}, nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []trust.Step{
+			{Stage: "pre-connect", Command: command.Command{Command: []string{"echo", "pre"}}},
+			{Stage: "post-connect", Command: command.Command{Command: []string{"echo", "post"}}},
+			{Stage: "command", Command: command.Command{Command: []string{"echo", "main"}, Interactive: true}},
+			{Stage: "teardown", Command: command.Command{Command: []string{"echo", "teardown"}}},
+		}, hooks.steps())
+	})
+
+	t.Run("omit an empty main command", func(t *testing.T) {
+		hooks, err := newHooks(map[string]string{}, nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []trust.Step{}, hooks.steps())
+	})
+}
@@ -0,0 +1,139 @@
+package execforward
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// podLimits are the ceilings set by the annotations of a forwarded pod.
+type podLimits struct {
+	pod    string
+	limits annotation.Limits
+}
+
+// sessionLimits returns the limits of the session: those requested by the user, lowered to the smallest ceilings set
+// by the forwarded pods' annotations, which also apply when the user requested none.
+func sessionLimits(config *Config, ceilings []podLimits, streams genericclioptions.IOStreams) annotation.Limits {
+	idleCeiling, idlePod := smallestCeiling(ceilings, func(l annotation.Limits) time.Duration { return l.IdleTimeout })
+
+	idleTimeout, lowered := limit(config.IdleTimeout, idleCeiling)
+	if lowered {
+		fmt.Fprintf(streams.ErrOut, "Idle timeout lowered to %s, the maximum allowed by pod %s\n", idleTimeout, idlePod)
+	}
+
+	durationCeiling, durationPod := smallestCeiling(ceilings, func(l annotation.Limits) time.Duration { return l.MaxDuration })
+
+	maxDuration, lowered := limit(config.MaxDuration, durationCeiling)
+	if lowered {
+		fmt.Fprintf(streams.ErrOut, "Maximum duration lowered to %s, the maximum allowed by pod %s\n", maxDuration, durationPod)
+	}
+
+	return annotation.Limits{IdleTimeout: idleTimeout, MaxDuration: maxDuration}
+}
+
+// smallestCeiling returns the smallest non-zero ceiling selected from the pods' limits along with the pod setting it,
+// or zero when no pod sets one.
+func smallestCeiling(ceilings []podLimits, selectLimit func(annotation.Limits) time.Duration) (time.Duration, string) {
+	var smallest time.Duration
+
+	pod := ""
+
+	for _, c := range ceilings {
+		if d := selectLimit(c.limits); d != 0 && (smallest == 0 || d < smallest) {
+			smallest, pod = d, c.pod
+		}
+	}
+
+	return smallest, pod
+}
+
+// limit returns the requested duration bounded by the ceiling, where zero is unbounded, and whether the requested
+// duration exceeded the ceiling.
+func limit(requested time.Duration, ceiling time.Duration) (time.Duration, bool) {
+	if ceiling == 0 || (requested != 0 && requested <= ceiling) {
+		return requested, false
+	}
+
+	return ceiling, requested != 0
+}
+
+// maxIdleCheckInterval bounds how often the traffic of idle sessions is checked.
+const maxIdleCheckInterval = time.Second
+
+// watchLimits returns a channel receiving an error once the session has gone without traffic for its idle timeout, or
+// has lasted its maximum duration. Nothing is received once ctx is done.
+func (s *Session) watchLimits(ctx context.Context) <-chan error {
+	expiredChan := make(chan error, 1)
+
+	if s.limits.IdleTimeout == 0 && s.limits.MaxDuration == 0 {
+		return expiredChan
+	}
+
+	go func() {
+		var deadline <-chan time.Time
+
+		if s.limits.MaxDuration > 0 {
+			timer := time.NewTimer(s.limits.MaxDuration)
+			defer timer.Stop()
+
+			deadline = timer.C
+		}
+
+		var tick <-chan time.Time
+
+		if s.limits.IdleTimeout > 0 {
+			interval := s.limits.IdleTimeout / 10
+			if interval > maxIdleCheckInterval {
+				interval = maxIdleCheckInterval
+			}
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			tick = ticker.C
+		}
+
+		traffic, lastTraffic := s.traffic(), time.Now()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline:
+				expiredChan <- newError(ExitCodeExpired, fmt.Errorf("session reached its maximum duration of %s", s.limits.MaxDuration))
+
+				return
+			case now := <-tick:
+				if t := s.traffic(); t != traffic {
+					traffic, lastTraffic = t, now
+
+					continue
+				}
+
+				if now.Sub(lastTraffic) >= s.limits.IdleTimeout {
+					expiredChan <- newError(ExitCodeExpired, fmt.Errorf("no traffic for %s, the session's idle timeout", s.limits.IdleTimeout))
+
+					return
+				}
+			}
+		}
+	}()
+
+	return expiredChan
+}
+
+// traffic returns the number of bytes sent and received through the session's forwarded ports.
+func (s *Session) traffic() uint64 {
+	var n uint64
+
+	for _, t := range s.metrics {
+		stats := t.metrics.Stats()
+		n += stats.BytesSent + stats.BytesReceived
+	}
+
+	return n
+}
@@ -0,0 +1,112 @@
+package execforward
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+)
+
+func TestLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		requested time.Duration
+		ceiling   time.Duration
+
+		expected time.Duration
+		lowered  bool
+	}{
+		{
+			name: "unbounded",
+		},
+		{
+			name:      "requested without a ceiling",
+			requested: time.Hour,
+			expected:  time.Hour,
+		},
+		{
+			name:     "ceiling without a request",
+			ceiling:  time.Hour,
+			expected: time.Hour,
+		},
+		{
+			name:      "requested below the ceiling",
+			requested: time.Minute,
+			ceiling:   time.Hour,
+			expected:  time.Minute,
+		},
+		{
+			name:      "requested above the ceiling",
+			requested: 2 * time.Hour,
+			ceiling:   time.Hour,
+			expected:  time.Hour,
+			lowered:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, lowered := limit(tc.requested, tc.ceiling)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.lowered, lowered)
+		})
+	}
+}
+
+func TestSmallestCeiling(t *testing.T) {
+	t.Parallel()
+
+	idleTimeout := func(l annotation.Limits) time.Duration { return l.IdleTimeout }
+
+	cases := []struct {
+		name string
+
+		ceilings []podLimits
+
+		expected    time.Duration
+		expectedPod string
+	}{
+		{
+			name:     "no ceilings",
+			ceilings: []podLimits{{pod: "db-0"}, {pod: "redis-0"}},
+		},
+		{
+			name: "ceiling of the first pod",
+			ceilings: []podLimits{
+				{pod: "db-0", limits: annotation.Limits{IdleTimeout: time.Hour}},
+				{pod: "redis-0"},
+			},
+			expected:    time.Hour,
+			expectedPod: "db-0",
+		},
+		{
+			name: "smallest ceiling of another pod",
+			ceilings: []podLimits{
+				{pod: "db-0", limits: annotation.Limits{IdleTimeout: time.Hour}},
+				{pod: "redis-0", limits: annotation.Limits{IdleTimeout: time.Minute}},
+			},
+			expected:    time.Minute,
+			expectedPod: "redis-0",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, pod := smallestCeiling(tc.ceilings, idleTimeout)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.expectedPod, pod)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package execforward
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+)
+
+// targetMetrics are the metrics of the forwarding connection to a target, along with the labels identifying it.
+type targetMetrics struct {
+	target    string
+	namespace string
+	pod       string
+	port      int
+	metrics   *forwarder.Metrics
+}
+
+// labels returns the Prometheus labels of the target.
+func (t targetMetrics) labels() string {
+	return fmt.Sprintf(`target="%s",namespace="%s",pod="%s",port="%d"`,
+		escapeLabel(t.target), escapeLabel(t.namespace), escapeLabel(t.pod), t.port)
+}
+
+// metricFamily describes a metric exposed for every target.
+type metricFamily struct {
+	name  string
+	kind  string
+	help  string
+	value func(forwarder.Stats) string
+}
+
+// metricFamilies are the metrics exposed by the metrics endpoint.
+var metricFamilies = []metricFamily{
+	{
+		name:  "exec_forward_bytes_sent_total",
+		kind:  "counter",
+		help:  "Bytes sent to the pod.",
+		value: func(s forwarder.Stats) string { return strconv.FormatUint(s.BytesSent, 10) },
+	},
+	{
+		name:  "exec_forward_bytes_received_total",
+		kind:  "counter",
+		help:  "Bytes received from the pod.",
+		value: func(s forwarder.Stats) string { return strconv.FormatUint(s.BytesReceived, 10) },
+	},
+	{
+		name:  "exec_forward_connections_total",
+		kind:  "counter",
+		help:  "Local connections forwarded to the pod.",
+		value: func(s forwarder.Stats) string { return strconv.FormatUint(s.Connections, 10) },
+	},
+	{
+		name:  "exec_forward_active_connections",
+		kind:  "gauge",
+		help:  "Local connections currently forwarded to the pod.",
+		value: func(s forwarder.Stats) string { return strconv.FormatInt(s.Active, 10) },
+	},
+	{
+		name:  "exec_forward_errors_total",
+		kind:  "counter",
+		help:  "Forwarded connections the pod reported an error for.",
+		value: func(s forwarder.Stats) string { return strconv.FormatUint(s.Errors, 10) },
+	},
+}
+
+// writeMetrics writes the metrics of the targets in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, targets []targetMetrics) error {
+	stats := make([]forwarder.Stats, len(targets))
+	for i, t := range targets {
+		stats[i] = t.metrics.Stats()
+	}
+
+	b := &strings.Builder{}
+
+	for _, f := range metricFamilies {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+
+		for i, t := range targets {
+			fmt.Fprintf(b, "%s{%s} %s\n", f.name, t.labels(), f.value(stats[i]))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// labelReplacer escapes label values as required by the Prometheus text exposition format.
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value.
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+// metricsReadHeaderTimeout bounds reading the headers of requests to the metrics endpoint.
+const metricsReadHeaderTimeout = 5 * time.Second
+
+// listenMetrics serves the metrics of the session's forwarding connections on addr, under /metrics.
+func (s *Session) listenMetrics(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("starting metrics endpoint: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		_ = writeMetrics(w, s.metrics)
+	})
+
+	s.metricsServer = &http.Server{Handler: mux, ReadHeaderTimeout: metricsReadHeaderTimeout}
+	s.metricsAddr = l.Addr().String()
+
+	go func() {
+		_ = s.metricsServer.Serve(l)
+	}()
+
+	fmt.Fprintf(s.streams.Out, "Metrics listening on http://%s/metrics\n", s.metricsAddr)
+
+	return nil
+}
+
+// MetricsAddr returns the address the metrics endpoint listens on, or an empty string when it is not served.
+func (s *Session) MetricsAddr() string {
+	return s.metricsAddr
+}
+
+// printStats prints a line summing up the traffic forwarded to each target.
+func (s *Session) printStats() {
+	for _, t := range s.metrics {
+		stats := t.metrics.Stats()
+
+		fmt.Fprintf(s.streams.ErrOut, "Stats for %s: %s sent, %s received, %d connections, %d errors\n",
+			t.target, formatBytes(stats.BytesSent), formatBytes(stats.BytesReceived), stats.Connections, stats.Errors)
+	}
+}
+
+// formatBytes formats a number of bytes with binary prefixes, e.g., 1.5 KiB.
+func formatBytes(n uint64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,38 @@
+package execforward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		n        uint64
+		expected string
+	}{
+		{n: 0, expected: "0 B"},
+		{n: 1023, expected: "1023 B"},
+		{n: 1536, expected: "1.5 KiB"},
+		{n: 5 << 20, expected: "5.0 MiB"},
+		{n: 3 << 30, expected: "3.0 GiB"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.expected, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, formatBytes(tc.n))
+		})
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `db \"primary\"\\n\n`, escapeLabel("db \"primary\"\\n\n"))
+}
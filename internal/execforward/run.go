@@ -2,68 +2,515 @@ package execforward
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
 	"github.com/takescoop/kubectl-exec-forward/internal/command"
 	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+	"github.com/takescoop/kubectl-exec-forward/internal/proxy"
+	"github.com/takescoop/kubectl-exec-forward/internal/tracing"
+	"github.com/takescoop/kubectl-exec-forward/internal/trust"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
 // Run executes hooks found on the passed resource's underlying pod annotations and opens a forwarding connection to the resource.
+// Returned errors carry the exit code the plugin should exit with, see ExitCode.
 func Run(ctx context.Context, client *forwarder.Client, hooksConfig *Config, cliArgs map[string]string, resource string, portMap string, streams genericclioptions.IOStreams) error {
-	fwdConfig, err := client.NewConfig(resource, portMap)
+	session, err := Start(ctx, client, hooksConfig, cliArgs, resource, portMap, streams)
 	if err != nil {
+		// The session was closed by the user before the connection was established.
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+
 		return err
 	}
 
+	return session.Wait()
+}
+
+// Session is an open forwarding connection along with the commands run over it.
+type Session struct {
+	ports  []forwarder.Connection
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	hooks    *Hooks
+	config   *command.Config
+	args     command.Args
+	streams  genericclioptions.IOStreams
+	events   Events
+	stopChan chan struct{}
+	proxy    *proxy.Proxy
+	release  func(context.Context)
+	// metrics account for the traffic forwarded to each target, in the order of the targets.
+	metrics       []targetMetrics
+	metricsServer *http.Server
+	metricsAddr   string
+	// limits bound how long the session may go without traffic and last once connected.
+	limits annotation.Limits
+	// span traces the session, from resolving its targets until it has ended. It is nil for untraced sessions.
+	span trace.Span
+}
+
+// Start runs the pre-connect commands and opens a forwarding connection to the resource. It returns once the connection
+// is established, while the post-connect commands and the main command run in the background until the session ends.
+// The session is shut down once ctx is done.
+func Start(ctx context.Context, client *forwarder.Client, hooksConfig *Config, cliArgs map[string]string, resource string, portMap string, streams genericclioptions.IOStreams) (*Session, error) {
+	ctx, span := tracing.Start(ctx, "exec-forward",
+		attribute.String("exec_forward.resource", resource),
+		attribute.Int("exec_forward.targets", len(hooksConfig.Targets)+1),
+	)
+
+	s, err := startTargets(ctx, client, hooksConfig, cliArgs, resource, portMap, streams)
+	if err != nil {
+		// Sessions that were started end the span once closed.
+		tracing.End(span, err)
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// startTargets is Start within the session's span, spawning and waking up each target before forwarding to them.
+func startTargets(ctx context.Context, client *forwarder.Client, hooksConfig *Config, cliArgs map[string]string, resource string, portMap string, streams genericclioptions.IOStreams) (*Session, error) {
+	targets := append([]Target{{Name: hooksConfig.Name, Resource: resource, Port: portMap}}, hooksConfig.Targets...)
+
+	if err := validateTargets(targets); err != nil {
+		return nil, newError(ExitCodeConfig, err)
+	}
+
+	releases := []func(context.Context){}
+
+	release := func(ctx context.Context) {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i](ctx)
+		}
+	}
+
+	clients := make([]*forwarder.Client, len(targets))
+
+	for i := range targets {
+		// Names are resolved first, since spawning a Job changes the resource forwarded to.
+		targets[i].Name = targets[i].name()
+
+		c, err := resolveClient(client, hooksConfig, targets[i])
+		if err != nil {
+			releaseWithin(release, releaseTimeout)
+
+			return nil, newError(ExitCodeConfig, err)
+		}
+
+		clients[i] = c
+
+		spawned, releaseTarget, err := prepare(ctx, c, hooksConfig, targets[i].Resource, streams)
+		if err != nil {
+			releaseWithin(release, releaseTimeout)
+
+			return nil, err
+		}
+
+		targets[i].Resource = spawned
+		releases = append(releases, releaseTarget)
+	}
+
+	s, err := start(ctx, clients, hooksConfig, cliArgs, targets, streams, release)
+	if err != nil {
+		releaseWithin(release, releaseTimeout)
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// resolveClient returns the client forwarding to the target: the session's client, unless the target is found in
+// another context or namespace.
+func resolveClient(client *forwarder.Client, config *Config, t Target) (*forwarder.Client, error) {
+	if t.Context == "" && t.Namespace == "" {
+		return client, nil
+	}
+
+	if config.ClientFor == nil {
+		return nil, fmt.Errorf("target %s cannot be found in another context or namespace", t.Name)
+	}
+
+	c, err := config.ClientFor(t.Context, t.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("target %s: %w", t.Name, err)
+	}
+
+	return c, nil
+}
+
+// prepare spawns and wakes up the resource as configured, returning the resource to forward to along with a function
+// releasing it.
+func prepare(ctx context.Context, client *forwarder.Client, hooksConfig *Config, resource string, streams genericclioptions.IOStreams) (string, func(context.Context), error) {
+	resource, deleteJob, err := spawn(ctx, client, hooksConfig, resource, streams)
+	if err != nil {
+		return "", nil, err
+	}
+
+	restoreReplicas, err := wake(ctx, client, hooksConfig, resource, streams)
+	if err != nil {
+		releaseWithin(deleteJob, releaseTimeout)
+
+		return "", nil, err
+	}
+
+	return resource, func(ctx context.Context) {
+		restoreReplicas(ctx)
+		deleteJob(ctx)
+	}, nil
+}
+
+// releaseWithin calls release with a context expiring after timeout, for targets released before a session has
+// started.
+func releaseWithin(release func(context.Context), timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	release(ctx)
+}
+
+// start is Start once the targets have been spawned and woken up, forwarding to each target with the client at the
+// same index. Commands are read from the first target's pod, and the session calls release once it has ended.
+func start(ctx context.Context, clients []*forwarder.Client, hooksConfig *Config, cliArgs map[string]string, targets []Target, streams genericclioptions.IOStreams, release func(context.Context)) (*Session, error) {
+	client := clients[0]
+
+	fwdConfig, err := client.NewConfig(ctx, targets[0].Resource, targets[0].Port, hooksConfig.Container)
+	if err != nil {
+		return nil, newError(ExitCodeConfig, err)
+	}
+
 	localPort, err := fwdConfig.GetLocalPort()
 	if err != nil {
-		return err
+		return nil, newError(ExitCodeConfig, err)
 	}
 
 	hooksConfig.LocalPort = localPort
 
-	args, err := annotation.ParseArgs(fwdConfig.Pod.Annotations)
+	annotations, err := annotation.Merge(annotation.ForContainer(fwdConfig.Pod.Annotations, fwdConfig.Container), hooksConfig.Annotations)
 	if err != nil {
-		return err
+		return nil, newError(ExitCodeConfig, err)
+	}
+
+	args, err := annotation.ParseArgs(annotations)
+	if err != nil {
+		return nil, newError(ExitCodeConfig, err)
 	}
 
 	args.Merge(cliArgs)
 
-	hooks, err := newHooks(fwdConfig.Pod.Annotations, hooksConfig)
+	hooks, err := newHooks(annotations, hooksConfig)
 	if err != nil {
-		return err
+		return nil, newError(ExitCodeConfig, err)
 	}
 
-	outputs := command.Outputs{}
-	commandConfig := &command.Config{
-		LocalPort: hooksConfig.LocalPort,
-		Verbose:   hooksConfig.Verbose,
+	if hooksConfig.Trust != nil {
+		canonical, err := annotation.Canonical(annotations)
+		if err != nil {
+			return nil, newError(ExitCodeConfig, err)
+		}
+
+		if err := hooksConfig.Trust.Check(trust.Request{
+			Namespace: fwdConfig.Pod.Namespace,
+			Pod:       fwdConfig.Pod.Name,
+			Canonical: canonical,
+			Signature: annotations[annotation.Signature],
+			Steps:     hooks.steps(),
+			// Outputs and the ports of other targets are not known yet, so commands using them are shown as templates.
+			Data: command.TemplateData{LocalPort: localPort, Args: args},
+		}, hooksConfig.AssumeYes, streams); err != nil {
+			return nil, newError(ExitCodeDenied, err)
+		}
 	}
 
-	if outputs, err = hooks.Pre.Execute(ctx, commandConfig, args, outputs, streams); err != nil {
-		return err
+	limits, err := annotation.ParseLimits(annotations)
+	if err != nil {
+		return nil, newError(ExitCodeConfig, err)
+	}
+
+	ceilings := []podLimits{{pod: fwdConfig.Pod.Name, limits: limits}}
+
+	permissions, err := annotation.ParsePermissions(annotations)
+	if err != nil {
+		return nil, newError(ExitCodeConfig, err)
+	}
+
+	if hooksConfig.Ephemeral != nil {
+		permissions = append(permissions, forwarder.EphemeralContainerAccess(fwdConfig))
+	}
+
+	if err := client.CheckAccess(ctx, fwdConfig, permissions); err != nil {
+		return nil, newError(ExitCodeDenied, err)
+	}
+
+	fwdConfigs := []*forwarder.Config{fwdConfig}
+	targetPorts := map[string]command.Target{targets[0].Name: {LocalPort: localPort}}
+
+	for i, t := range targets[1:] {
+		targetClient := clients[i+1]
+
+		c, err := targetClient.NewConfig(ctx, t.Resource, t.Port, "")
+		if err != nil {
+			return nil, newError(ExitCodeConfig, err)
+		}
+
+		port, err := c.GetLocalPort()
+		if err != nil {
+			return nil, newError(ExitCodeConfig, err)
+		}
+
+		if err := targetClient.CheckAccess(ctx, c, nil); err != nil {
+			return nil, newError(ExitCodeDenied, err)
+		}
+
+		// Every target's ceilings apply, so that they cannot be escaped by forwarding to another target first.
+		limits, err := annotation.ParseLimits(c.Pod.Annotations)
+		if err != nil {
+			return nil, newError(ExitCodeConfig, err)
+		}
+
+		ceilings = append(ceilings, podLimits{pod: c.Pod.Name, limits: limits})
+
+		fwdConfigs = append(fwdConfigs, c)
+		targetPorts[t.Name] = command.Target{LocalPort: port}
+	}
+
+	if hooksConfig.Ephemeral != nil {
+		name, added, err := client.AddEphemeralContainer(ctx, fwdConfig, *hooksConfig.Ephemeral)
+		if err != nil {
+			return nil, newError(ExitCodeTunnel, err)
+		}
+
+		if added {
+			fmt.Fprintf(streams.ErrOut, "Added ephemeral container %s to pod %s\n", name, fwdConfig.Pod.Name)
+		} else {
+			fmt.Fprintf(streams.ErrOut, "Reusing ephemeral container %s of pod %s\n", name, fwdConfig.Pod.Name)
+		}
+	}
+
+	// Until the proxy listens, commands are given its configured address, like the configured local port.
+	proxyAddr := ""
+	if hooksConfig.Proxy != nil {
+		proxyAddr = hooksConfig.Proxy.SOCKSAddr
+	}
+
+	s := &Session{
+		done:  make(chan struct{}),
+		hooks: hooks,
+		config: &command.Config{
+			LocalPort:   hooksConfig.LocalPort,
+			ProxyAddr:   proxyAddr,
+			Targets:     targetPorts,
+			Verbose:     hooksConfig.Verbose,
+			Cache:       hooksConfig.Cache,
+			CacheScope:  cacheScope(client.Cluster(), fwdConfig.Pod),
+			NoTTY:       hooksConfig.NoTTY,
+			Interrupts:  hooksConfig.Interrupts,
+			GracePeriod: hooksConfig.GracePeriod,
+			Runner:      hooksConfig.Runner,
+		},
+		args:     args,
+		streams:  streams,
+		events:   hooksConfig.events(),
+		stopChan: make(chan struct{}),
+		release:  release,
+		limits:   sessionLimits(hooksConfig, ceilings, streams),
+		// The span in ctx is the session's own, started by Start.
+		span: trace.SpanFromContext(ctx),
+	}
+
+	outputs, err := s.stage(ctx, StagePreConnect, hooks.Pre, s.config, command.Outputs{}, streams)
+	if err != nil {
+		return nil, newError(ExitCodeHook, err)
+	}
+
+	// Each goroutine sends at most once on the buffered channels, so none blocks once the session has ended.
+	fwdErrChan := make(chan error, len(fwdConfigs))
+	readyChans := make([]chan forwarder.Connection, len(fwdConfigs))
+
+	spans := make([]trace.Span, len(fwdConfigs))
+
+	for i, c := range fwdConfigs {
+		readyChan := make(chan forwarder.Connection, 1)
+		readyChans[i] = readyChan
+
+		c.Metrics = &forwarder.Metrics{}
+		s.metrics = append(s.metrics, targetMetrics{
+			target:    targets[i].Name,
+			namespace: c.Pod.Namespace,
+			pod:       c.Pod.Name,
+			metrics:   c.Metrics,
+		})
+
+		_, spans[i] = tracing.Start(ctx, "forward",
+			attribute.String("exec_forward.target", targets[i].Name),
+			attribute.String("k8s.pod.name", c.Pod.Name),
+			attribute.String("k8s.namespace.name", c.Pod.Namespace),
+		)
+
+		go func(client *forwarder.Client, c *forwarder.Config) {
+			if err := client.Forward(c, readyChan, s.stopChan); err != nil {
+				fwdErrChan <- newError(ExitCodeTunnel, err)
+			}
+		}(clients[i], c)
+	}
+
+	conns := make([]forwarder.Connection, len(fwdConfigs))
+
+	for i, readyChan := range readyChans {
+		select {
+		case conns[i] = <-readyChan:
+			tracing.End(spans[i], nil)
+		case err := <-fwdErrChan:
+			endSpans(spans[i:], err)
+			s.abort(outputs, err)
+
+			return nil, err
+		case <-ctx.Done():
+			endSpans(spans[i:], ctx.Err())
+			s.abort(outputs, nil)
+
+			return nil, ctx.Err()
+		}
+
+		s.config.Targets[targets[i].Name] = command.Target{LocalPort: conns[i].Local}
+		s.metrics[i].port = conns[i].Remote
+	}
+
+	conn := conns[0]
+
+	// From now on, commands, including teardown ones, are given the established local port rather than the configured one.
+	s.config.LocalPort = conn.Local
+
+	if hooksConfig.Proxy != nil {
+		if err := s.listenProxy(*hooksConfig.Proxy, conn); err != nil {
+			err = newError(ExitCodeTunnel, err)
+			s.abort(outputs, err)
+
+			return nil, err
+		}
 	}
 
-	hookErrChan := make(chan error)
-	fwdErrChan := make(chan error)
-	stopChan := make(chan struct{})
-	readyChan := make(chan forwarder.Connection)
-	commandDoneChan := make(chan bool)
+	if hooksConfig.MetricsAddr != "" {
+		if err := s.listenMetrics(hooksConfig.MetricsAddr); err != nil {
+			err = newError(ExitCodeConfig, err)
+			s.abort(outputs, err)
+
+			return nil, err
+		}
+	}
+
+	s.ports = conns
+	s.events.Connected(s.Ports())
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+
+	go s.run(sessionCtx, hooksConfig, outputs)
+
+	return s, nil
+}
+
+// endSpans ends the spans of forwarding connections that were not established, recording err.
+func endSpans(spans []trace.Span, err error) {
+	for _, span := range spans {
+		tracing.End(span, err)
+	}
+}
+
+// listenProxy starts the local proxies, dialing connections through the SOCKS5 server the connection is forwarded to.
+func (s *Session) listenProxy(config proxy.Config, conn forwarder.Connection) error {
+	p, err := proxy.Listen(config, proxy.RelayDialer(net.JoinHostPort("127.0.0.1", strconv.Itoa(conn.Local))))
+	if err != nil {
+		return fmt.Errorf("starting proxy: %w", err)
+	}
+
+	s.proxy = p
+	s.config.ProxyAddr = p.SOCKSAddr()
+
+	fmt.Fprintf(s.streams.Out, "SOCKS5 proxy listening on %s\n", p.SOCKSAddr())
+
+	if p.HTTPAddr() != "" {
+		fmt.Fprintf(s.streams.Out, "HTTP proxy listening on %s\n", p.HTTPAddr())
+	}
+
+	return nil
+}
+
+// Ports returns the ports of the forwarding connections, in the order of the targets.
+func (s *Session) Ports() []forwarder.Connection {
+	return append([]forwarder.Connection{}, s.ports...)
+}
+
+// Wait waits for the session to end and returns the error that ended it, if any.
+func (s *Session) Wait() error {
+	<-s.done
 
-	cancelCtx, cancel := context.WithCancel(ctx)
+	return s.err
+}
+
+// Close shuts the session down, running its teardown commands, and waits for it to end.
+func (s *Session) Close() error {
+	s.cancel()
+
+	return s.Wait()
+}
+
+// run runs the post-connect commands and the main command, then ends the session once the main command has finished,
+// unless the connection persists, a command failed or ctx is done.
+func (s *Session) run(ctx context.Context, hooksConfig *Config, outputs command.Outputs) {
+	// Each goroutine sends at most once on its buffered channel, so neither blocks once the session has ended.
+	hookErrChan := make(chan error, 1)
+	commandDoneChan := make(chan bool, 1)
+	commandsExited := make(chan struct{})
+	expiredChan := s.watchLimits(ctx)
+
+	// sessionOutputs holds the outputs of the post-connect commands once they have run. It is only read after
+	// commandsExited is closed.
+	sessionOutputs := outputs
 
 	go func() {
-		conn := <-readyChan
+		defer close(commandsExited)
+
+		config := *s.config
+
+		outputs, err := s.stage(ctx, StagePostConnect, s.hooks.Post, &config, outputs, s.streams)
+		if err != nil {
+			hookErrChan <- newError(ExitCodeHook, err)
 
-		commandConfig.LocalPort = conn.Local
+			return
+		}
+
+		sessionOutputs = outputs
 
-		if outputs, err = hooks.Post.Execute(cancelCtx, commandConfig, args, outputs, streams); err != nil {
-			hookErrChan <- err
+		streams, err := commandStreams(hooksConfig, s.streams)
+		if err != nil {
+			hookErrChan <- newError(ExitCodeConfig, err)
+
+			return
 		}
 
-		if _, err = hooks.Command.Execute(cancelCtx, commandConfig, args, outputs, streams); err != nil {
-			hookErrChan <- err
+		if _, err := s.stage(ctx, StageCommand, s.hooks.main(), &config, outputs, streams); err != nil {
+			hookErrChan <- newCommandError(err)
+
+			return
 		}
 
 		if !hooksConfig.Persist {
@@ -71,36 +518,221 @@ func Run(ctx context.Context, client *forwarder.Client, hooksConfig *Config, cli
 		}
 	}()
 
-	go func() {
-		if err := client.Forward(fwdConfig, readyChan, stopChan); err != nil {
-			fwdErrChan <- err
-		}
-	}()
+	var runErr error
 
-	for {
-		select {
-		case err := <-hookErrChan:
-			stopChan <- struct{}{}
+	select {
+	case runErr = <-hookErrChan:
+	case runErr = <-expiredChan:
+		fmt.Fprintf(s.streams.ErrOut, "Closing session: %v\n", runErr)
+	case <-commandDoneChan:
+	case <-ctx.Done():
+	}
 
-			cancel()
+	// Commands still running are asked to terminate, and are killed once the grace period has passed.
+	s.cancel()
 
-			return err
-		case err := <-fwdErrChan:
-			cancel()
+	shutdownCtx, cancel := s.shutdownContext()
+	defer cancel()
 
-			return err
-		case <-commandDoneChan:
-			stopChan <- struct{}{}
+	teardownOutputs := outputs
 
-			cancel()
+	select {
+	case <-commandsExited:
+		teardownOutputs = sessionOutputs
+	case <-shutdownCtx.Done():
+		fmt.Fprintln(s.streams.ErrOut, "Timed out waiting for commands to exit")
+	}
 
-			return nil
-		case <-ctx.Done():
-			stopChan <- struct{}{}
+	s.close(shutdownCtx, teardownOutputs, runErr)
+}
 
-			cancel()
+// shutdownContext returns the context bounding the shutdown of the session from now on: running commands, teardown
+// commands and releasing the targets share the grace period, along with the time killed commands are waited for.
+func (s *Session) shutdownContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.config.GracePeriod+killTimeout)
+}
 
-			return nil
+// abort closes a session that failed to start, see close.
+func (s *Session) abort(outputs command.Outputs, err error) {
+	ctx, cancel := s.shutdownContext()
+	defer cancel()
+
+	s.close(ctx, outputs, err)
+}
+
+// close runs the teardown commands, closes the connection and ends the session with the passed error, or with the
+// teardown error when there is none. Teardown commands are cancelled, and the targets are no longer waited for to be
+// released, once ctx is done.
+func (s *Session) close(ctx context.Context, outputs command.Outputs, err error) {
+	if teardownErr := s.teardown(ctx, outputs); teardownErr != nil && err == nil {
+		err = newError(ExitCodeHook, teardownErr)
+	}
+
+	if s.proxy != nil {
+		s.proxy.Close()
+	}
+
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
+
+	close(s.stopChan)
+
+	if s.ports != nil {
+		s.printStats()
+	}
+	s.release(ctx)
+
+	if s.span != nil {
+		tracing.End(s.span, err)
+	}
+
+	s.err = err
+	s.events.Closed(err)
+
+	close(s.done)
+}
+
+// releaseTimeout bounds restoring the replicas of a woken up workload, or deleting a spawned Job, when the session fails
+// before it has started. Once it has, releasing is bounded by the grace period.
+const releaseTimeout = 30 * time.Second
+
+// wake wakes the resource up when configured to, see forwarder.Client.Wake. The returned function releases the
+// resource within the passed context, and may be called more than once.
+func wake(ctx context.Context, client *forwarder.Client, config *Config, resource string, streams genericclioptions.IOStreams) (func(context.Context), error) {
+	if !config.Wake {
+		return func(context.Context) {}, nil
+	}
+
+	release, err := client.Wake(ctx, resource, config.WakeTimeout)
+	if err != nil {
+		return nil, newError(ExitCodeTunnel, err)
+	}
+
+	var once sync.Once
+
+	return func(ctx context.Context) {
+		once.Do(func() {
+			if err := release(ctx); err != nil {
+				fmt.Fprintf(streams.ErrOut, "Unable to restore the replicas of %s: %v\n", resource, err)
+			}
+		})
+	}, nil
+}
+
+// spawn spawns a Job from the resource when it is a CronJob, see forwarder.Client.Spawn, returning the resource to
+// forward to. The returned function deletes the Job within the passed context, and may be called more than once.
+func spawn(ctx context.Context, client *forwarder.Client, config *Config, resource string, streams genericclioptions.IOStreams) (string, func(context.Context), error) {
+	timeout := config.SpawnTimeout
+	if timeout == 0 {
+		timeout = forwarder.DefaultSpawnTimeout
+	}
+
+	ttl := config.JobTTL
+	if ttl == 0 {
+		ttl = forwarder.DefaultJobTTL
+	}
+
+	spawned, cleanup, err := client.Spawn(ctx, resource, timeout, ttl)
+	if err != nil {
+		return "", nil, newError(ExitCodeTunnel, err)
+	}
+
+	var once sync.Once
+
+	return spawned, func(ctx context.Context) {
+		once.Do(func() {
+			if err := cleanup(ctx); err != nil {
+				fmt.Fprintf(streams.ErrOut, "Unable to delete the job spawned from %s: %v\n", resource, err)
+			}
+		})
+	}, nil
+}
+
+// killTimeout is how long commands killed at the end of the grace period are waited for before shutdown continues
+// without them.
+const killTimeout = time.Second
+
+// teardown runs the teardown commands while the connection is still open. They run even when the session was
+// interrupted, and are cancelled once ctx, bounding the shutdown, is done.
+func (s *Session) teardown(ctx context.Context, outputs command.Outputs) error {
+	if len(s.hooks.Teardown) == 0 {
+		return nil
+	}
+
+	if s.span != nil {
+		ctx = trace.ContextWithSpan(ctx, s.span)
+	}
+
+	// The grace period has been spent once the context expires, so commands still running are killed right away.
+	config := *s.config
+	config.GracePeriod = 0
+
+	_, err := s.stage(ctx, StageTeardown, s.hooks.Teardown, &config, outputs, s.streams)
+
+	return err
+}
+
+// stage runs the commands of a lifecycle stage, notifying the session's events.
+func (s *Session) stage(ctx context.Context, stage string, commands command.Commands, config *command.Config, outputs command.Outputs, streams genericclioptions.IOStreams) (command.Outputs, error) {
+	if len(commands) == 0 {
+		return outputs, nil
+	}
+
+	s.events.StageStarted(stage)
+
+	ctx, span := tracing.Start(ctx, stage)
+	outputs, err := commands.Execute(ctx, config, s.args, outputs, streams)
+	tracing.End(span, err)
+
+	s.events.StageFinished(stage, err)
+
+	return outputs, err
+}
+
+// commandStreams returns the streams the main command is attached to. Unless NoTTY is set, these are the passed streams.
+// The main command's output is opened here when the config opens it lazily.
+func commandStreams(config *Config, streams genericclioptions.IOStreams) (genericclioptions.IOStreams, error) {
+	if !config.NoTTY {
+		return streams, nil
+	}
+
+	s := streams
+	s.In = config.Stdin
+
+	if s.In == nil {
+		s.In = strings.NewReader("")
+	}
+
+	if config.Stdout != nil {
+		s.Out = config.Stdout
+	}
+
+	if config.OpenStdout != nil {
+		out, err := config.OpenStdout()
+		if err != nil {
+			return s, fmt.Errorf("opening the main command's output: %w", err)
 		}
+
+		s.Out = out
 	}
+
+	return s, nil
+}
+
+// cacheScope returns the scope cached command outputs are shared in: pods with the same owner in the same cluster and
+// namespace share cached outputs. Pods of a Deployment are scoped to the Deployment rather than to their ReplicaSet,
+// which changes with every rollout.
+func cacheScope(cluster string, pod *corev1.Pod) string {
+	kind, name := "Pod", pod.Name
+
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		kind, name = owner.Kind, owner.Name
+
+		if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(name, "-"+hash) {
+			kind, name = "Deployment", strings.TrimSuffix(name, "-"+hash)
+		}
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s", cluster, pod.Namespace, kind, name)
 }
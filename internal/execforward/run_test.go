@@ -0,0 +1,215 @@
+package execforward
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestCacheScope(t *testing.T) {
+	t.Run("scope to the pod's controller", func(t *testing.T) {
+		controller := true
+
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      "db-0",
+			Namespace: "db",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: "db", Controller: &controller},
+			},
+		}}
+
+		assert.Equal(t, "https://cluster/db/StatefulSet/db", cacheScope("https://cluster", pod))
+	})
+
+	t.Run("scope to the deployment rather than its replica set", func(t *testing.T) {
+		controller := true
+
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      "db-7d4b9c8f6-x2k9p",
+			Namespace: "db",
+			Labels:    map[string]string{"pod-template-hash": "7d4b9c8f6"},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "db-7d4b9c8f6", Controller: &controller},
+			},
+		}}
+
+		assert.Equal(t, "https://cluster/db/Deployment/db", cacheScope("https://cluster", pod))
+	})
+
+	t.Run("scope to the pod without a controller", func(t *testing.T) {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "db"}}
+
+		assert.Equal(t, "https://cluster/db/Pod/db", cacheScope("https://cluster", pod))
+	})
+}
+
+func TestCommandStreams(t *testing.T) {
+	streams, in, out, _ := genericclioptions.NewTestIOStreams()
+
+	t.Run("attach to the passed streams", func(t *testing.T) {
+		actual, err := commandStreams(&Config{}, streams)
+		assert.NoError(t, err)
+
+		assert.Equal(t, in, actual.In)
+		assert.Equal(t, out, actual.Out)
+	})
+
+	t.Run("detach from the terminal without input", func(t *testing.T) {
+		actual, err := commandStreams(&Config{NoTTY: true}, streams)
+		assert.NoError(t, err)
+
+		b, err := io.ReadAll(actual.In)
+		assert.NoError(t, err)
+		assert.Empty(t, b)
+		assert.Equal(t, out, actual.Out)
+	})
+
+	t.Run("detach from the terminal with input and output", func(t *testing.T) {
+		stdin := strings.NewReader("select 1;")
+		stdout := &bytes.Buffer{}
+
+		actual, err := commandStreams(&Config{NoTTY: true, Stdin: stdin, Stdout: stdout}, streams)
+		assert.NoError(t, err)
+
+		assert.Equal(t, stdin, actual.In)
+		assert.Equal(t, stdout, actual.Out)
+		assert.Equal(t, streams.ErrOut, actual.ErrOut)
+	})
+
+	t.Run("open the output lazily", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		config := &Config{NoTTY: true, OpenStdout: func() (io.Writer, error) { return stdout, nil }}
+
+		actual, err := commandStreams(config, streams)
+		assert.NoError(t, err)
+
+		assert.Equal(t, stdout, actual.Out)
+	})
+
+	t.Run("error opening the output", func(t *testing.T) {
+		config := &Config{NoTTY: true, OpenStdout: func() (io.Writer, error) { return nil, errors.New("permission denied") }}
+
+		_, err := commandStreams(config, streams)
+		assert.EqualError(t, err, "opening the main command's output: permission denied")
+	})
+}
+
+func TestTeardown(t *testing.T) {
+	t.Run("run teardown commands with the session's outputs", func(t *testing.T) {
+		streams := genericclioptions.NewTestIOStreamsDiscard()
+
+		commands := command.Commands{{ID: "teardown", Command: []string{"echo", "{{.Outputs.token}}"}}}
+
+		session := &Session{hooks: &Hooks{Teardown: commands}, config: &command.Config{GracePeriod: time.Second}, streams: streams, events: NopEvents{}}
+
+		ctx, cancel := session.shutdownContext()
+		defer cancel()
+
+		err := session.teardown(ctx, command.Outputs{"token": "foo"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("cancel teardown commands once the shutdown deadline has passed", func(t *testing.T) {
+		streams := genericclioptions.NewTestIOStreamsDiscard()
+
+		commands := command.Commands{{Command: []string{"sleep", "10"}}}
+		start := time.Now()
+
+		session := &Session{hooks: &Hooks{Teardown: commands}, config: &command.Config{GracePeriod: 100 * time.Millisecond}, streams: streams, events: NopEvents{}}
+
+		ctx, cancel := session.shutdownContext()
+		defer cancel()
+
+		err := session.teardown(ctx, command.Outputs{})
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestClose(t *testing.T) {
+	t.Run("bound teardown commands and releasing targets by a single deadline", func(t *testing.T) {
+		streams := genericclioptions.NewTestIOStreamsDiscard()
+
+		commands := command.Commands{{Command: []string{"sleep", "10"}}}
+		released := make(chan error, 1)
+
+		session := &Session{
+			hooks:    &Hooks{Teardown: commands},
+			config:   &command.Config{GracePeriod: 100 * time.Millisecond},
+			streams:  streams,
+			events:   NopEvents{},
+			stopChan: make(chan struct{}),
+			done:     make(chan struct{}),
+			release: func(ctx context.Context) {
+				<-ctx.Done()
+				released <- ctx.Err()
+			},
+		}
+
+		start := time.Now()
+
+		session.abort(command.Outputs{}, nil)
+
+		assert.ErrorIs(t, <-released, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 100*time.Millisecond+killTimeout+time.Second)
+	})
+}
+
+// recordedEvents records the stages notified to it.
+type recordedEvents struct {
+	NopEvents
+
+	stages []string
+}
+
+func (e *recordedEvents) StageStarted(stage string) {
+	e.stages = append(e.stages, "started "+stage)
+}
+
+func (e *recordedEvents) StageFinished(stage string, err error) {
+	e.stages = append(e.stages, fmt.Sprintf("finished %s: %v", stage, err))
+}
+
+func TestSessionStage(t *testing.T) {
+	t.Run("run the commands of a stage and notify events", func(t *testing.T) {
+		runner := command.NewFakeRunner().Script(command.FakeResult{Stdout: "token"}, "aws")
+		events := &recordedEvents{}
+
+		session := &Session{args: command.Args{"username": "foo"}, events: events, streams: genericclioptions.NewTestIOStreamsDiscard()}
+
+		commands := command.Commands{
+			{ID: "token", Command: []string{"aws", "rds", "generate-db-auth-token", "--username", "{{.Args.username}}"}},
+			{Command: []string{"psql", "--port", "{{.LocalPort}}", "{{.Outputs.token}}"}},
+		}
+
+		outputs, err := session.stage(context.Background(), StagePostConnect, commands, &command.Config{LocalPort: 5432, Runner: runner}, command.Outputs{}, session.streams)
+		assert.NoError(t, err)
+		assert.Equal(t, command.Outputs{"token": "token"}, outputs)
+
+		assert.Equal(t, []command.FakeRun{
+			{Argv: []string{"aws", "rds", "generate-db-auth-token", "--username", "foo"}},
+			{Argv: []string{"psql", "--port", "5432", "token"}},
+		}, runner.Runs())
+		assert.Equal(t, []string{"started post-connect", "finished post-connect: <nil>"}, events.stages)
+	})
+
+	t.Run("skip stages without commands", func(t *testing.T) {
+		events := &recordedEvents{}
+		session := &Session{events: events}
+
+		_, err := session.stage(context.Background(), StageTeardown, command.Commands{}, &command.Config{}, command.Outputs{}, session.streams)
+		assert.NoError(t, err)
+		assert.Empty(t, events.stages)
+	})
+}
@@ -0,0 +1,486 @@
+package execforward
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+	"github.com/takescoop/kubectl-exec-forward/internal/kubetest"
+	"github.com/takescoop/kubectl-exec-forward/internal/proxy"
+	"github.com/takescoop/kubectl-exec-forward/internal/trust"
+	xproxy "golang.org/x/net/proxy"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// newTestPod returns a pod forwarded to in tests, with the passed annotations.
+func newTestPod(annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db", Annotations: annotations},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "postgres", Ports: []corev1.ContainerPort{{ContainerPort: 5432}}},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// newTestClient returns a forwarding client connected to the server.
+func newTestClient(t *testing.T, server *kubetest.Server) *forwarder.Client {
+	t.Helper()
+
+	client := forwarder.NewClient(time.Second, genericclioptions.NewTestIOStreamsDiscard())
+	require.NoError(t, client.Init(forwarder.NewRESTConfigGetter(server.RESTConfig(), "db"), "test"))
+
+	return client
+}
+
+// lifecycleAnnotations run a command at every stage of the lifecycle.
+var lifecycleAnnotations = map[string]string{
+	annotation.PreConnect:  `[{"id": "token", "command": ["generate-token", "{{.Args.username}}"]}]`,
+	annotation.PostConnect: `[{"command": ["pg_isready", "--port", "{{.LocalPort}}"]}]`,
+	annotation.Command:     `{"command": ["psql", "{{.Outputs.token}}"]}`,
+	annotation.Teardown:    `[{"command": ["revoke-token", "{{.Outputs.token}}", "--port", "{{.LocalPort}}"]}]`,
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		annotations map[string]string
+		overrides   map[string]string
+		results     map[string]command.FakeResult
+		authorize   func(attributes authorizationv1.ResourceAttributes) bool
+		// trust checks the commands against an empty trust policy, which prompts for them.
+		trust bool
+
+		programs []string
+		code     int
+	}{
+		{
+			name:        "run every stage",
+			annotations: lifecycleAnnotations,
+			programs:    []string{"generate-token", "pg_isready", "psql", "revoke-token"},
+		},
+		{
+			name:        "exit with the main command's exit code",
+			annotations: lifecycleAnnotations,
+			results:     map[string]command.FakeResult{"psql": {ExitCode: 3}},
+			programs:    []string{"generate-token", "pg_isready", "psql", "revoke-token"},
+			code:        3,
+		},
+		{
+			name:        "tear down after a failing post-connect command",
+			annotations: lifecycleAnnotations,
+			results:     map[string]command.FakeResult{"pg_isready": {ExitCode: 2}},
+			programs:    []string{"generate-token", "pg_isready", "revoke-token"},
+			code:        ExitCodeHook,
+		},
+		{
+			name:        "refuse to run without permissions",
+			annotations: lifecycleAnnotations,
+			authorize: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Subresource != "portforward"
+			},
+			programs: []string{},
+			code:     ExitCodeDenied,
+		},
+		{
+			name:        "override annotations",
+			annotations: lifecycleAnnotations,
+			overrides:   map[string]string{annotation.PostConnect: `[{"command": ["redis-cli", "ping"]}]`},
+			programs:    []string{"generate-token", "redis-cli", "psql", "revoke-token"},
+		},
+		{
+			name:        "run commands without annotations",
+			annotations: map[string]string{},
+			overrides:   map[string]string{annotation.Command: `{"command": ["psql"]}`},
+			programs:    []string{"psql"},
+		},
+		{
+			name:        "fail on invalid annotations",
+			annotations: map[string]string{annotation.PreConnect: "invalid"},
+			programs:    []string{},
+			code:        ExitCodeConfig,
+		},
+		{
+			name:        "fail on empty commands before prompting for them",
+			annotations: map[string]string{annotation.PreConnect: `[{"command": []}]`, annotation.Command: `{"command": ["psql"]}`},
+			trust:       true,
+			programs:    []string{},
+			code:        ExitCodeConfig,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := kubetest.NewServer(t)
+			server.Authorize = tc.authorize
+			server.Add(newTestPod(tc.annotations))
+
+			runner := command.NewFakeRunner()
+			for program, result := range tc.results {
+				runner.Script(result, program)
+			}
+
+			config := &Config{Runner: runner, GracePeriod: time.Second, Annotations: tc.overrides}
+
+			if tc.trust {
+				policy, err := trust.Load(filepath.Join(t.TempDir(), "trust.yaml"))
+				require.NoError(t, err)
+
+				config.Trust = policy
+			}
+
+			err := Run(context.Background(), newTestClient(t, server), config, map[string]string{"username": "foo"}, "pod/db-0", "0:5432", genericclioptions.NewTestIOStreamsDiscard())
+			assert.Equal(t, tc.code, ExitCode(err), "unexpected error: %v", err)
+
+			programs := []string{}
+			for _, run := range runner.Runs() {
+				programs = append(programs, run.Argv[0])
+			}
+
+			assert.Equal(t, tc.programs, programs)
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	server := kubetest.NewServer(t)
+	server.Add(newTestPod(lifecycleAnnotations))
+
+	runner := command.NewFakeRunner().Script(command.FakeResult{Stdout: "secret"}, "generate-token")
+	events := &recordedEvents{}
+
+	config := &Config{Runner: runner, Events: events, Persist: true, GracePeriod: time.Second}
+
+	session, err := Start(context.Background(), newTestClient(t, server), config, map[string]string{"username": "foo"}, "pod/db-0", "0:5432", genericclioptions.NewTestIOStreamsDiscard())
+	require.NoError(t, err)
+
+	ports := session.Ports()
+	require.Len(t, ports, 1)
+	assert.Equal(t, 5432, ports[0].Remote)
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(ports[0].Local)))
+	require.NoError(t, err)
+
+	_, err = c.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, c.(*net.TCPConn).CloseWrite())
+
+	b, err := io.ReadAll(c)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+	c.Close()
+
+	assert.NoError(t, session.Close())
+
+	local := strconv.Itoa(ports[0].Local)
+
+	assert.Equal(t, []command.FakeRun{
+		{Argv: []string{"generate-token", "foo"}},
+		{Argv: []string{"pg_isready", "--port", local}},
+		{Argv: []string{"psql", "secret"}, Interactive: true},
+		{Argv: []string{"revoke-token", "secret", "--port", local}},
+	}, runner.Runs())
+
+	assert.Equal(t, []string{
+		"started pre-connect", "finished pre-connect: <nil>",
+		"started post-connect", "finished post-connect: <nil>",
+		"started command", "finished command: <nil>",
+		"started teardown", "finished teardown: <nil>",
+	}, events.stages)
+}
+
+func TestStartTargets(t *testing.T) {
+	t.Parallel()
+
+	cache := newTestPod(nil)
+	cache.Name = "redis-0"
+
+	server := kubetest.NewServer(t)
+	server.Add(newTestPod(map[string]string{
+		annotation.PostConnect: `[{"command": ["migrate", "{{.LocalPort}}", "{{.Targets.db.LocalPort}}", "{{.Targets.cache.LocalPort}}", "{{.Targets.replica.LocalPort}}"]}]`,
+	}), cache)
+
+	// The replica is found in another cluster, played by another server.
+	replicaServer := kubetest.NewServer(t)
+	replicaServer.Add(newTestPod(nil))
+
+	runner := command.NewFakeRunner()
+	config := &Config{
+		Runner:      runner,
+		Persist:     true,
+		GracePeriod: time.Second,
+		Name:        "db",
+		Targets: []Target{
+			{Name: "cache", Resource: "pod/redis-0", Port: "0:6379"},
+			{Name: "replica", Resource: "pod/db-0", Port: "0:5432", Context: "replica"},
+		},
+		ClientFor: func(context string, namespace string) (*forwarder.Client, error) {
+			assert.Equal(t, "replica", context)
+
+			return newTestClient(t, replicaServer), nil
+		},
+	}
+
+	session, err := Start(context.Background(), newTestClient(t, server), config, nil, "pod/db-0", "0:5432", genericclioptions.NewTestIOStreamsDiscard())
+	require.NoError(t, err)
+
+	ports := session.Ports()
+	require.Len(t, ports, 3)
+	assert.Equal(t, 5432, ports[0].Remote)
+	assert.Equal(t, 6379, ports[1].Remote)
+	assert.Equal(t, 5432, ports[2].Remote)
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(ports[2].Local)))
+	require.NoError(t, err)
+
+	_, err = c.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	b := make([]byte, 5)
+	_, err = io.ReadFull(c, b)
+	require.NoError(t, err)
+	c.Close()
+
+	assert.Equal(t, []string{"5432"}, replicaServer.Forwarded())
+
+	require.Eventually(t, func() bool { return len(runner.Runs()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, session.Close())
+
+	db, cachePort, replica := strconv.Itoa(ports[0].Local), strconv.Itoa(ports[1].Local), strconv.Itoa(ports[2].Local)
+	assert.Equal(t, []string{"migrate", db, db, cachePort, replica}, runner.Runs()[0].Argv)
+}
+
+func TestStartProxy(t *testing.T) {
+	t.Parallel()
+
+	// The relay pod is played by a local SOCKS5 server dialing targets directly.
+	relay, err := proxy.Listen(proxy.Config{SOCKSAddr: "127.0.0.1:0"}, (&net.Dialer{}).DialContext)
+	require.NoError(t, err)
+	t.Cleanup(func() { relay.Close() })
+
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { target.Close() })
+
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		_, _ = conn.Write([]byte("hello"))
+	}()
+
+	server := kubetest.NewServer(t)
+	server.Upstream = relay.SOCKSAddr()
+	server.Add(newTestPod(map[string]string{
+		annotation.PostConnect: `[{"command": ["curl", "--proxy", "socks5h://{{.ProxyAddr}}", "http://db.db.svc"]}]`,
+	}))
+
+	runner := command.NewFakeRunner()
+	config := &Config{Runner: runner, Persist: true, GracePeriod: time.Second, Proxy: &proxy.Config{SOCKSAddr: "127.0.0.1:0"}}
+
+	session, err := Start(context.Background(), newTestClient(t, server), config, nil, "pod/db-0", "0:1080", genericclioptions.NewTestIOStreamsDiscard())
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(runner.Runs()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	proxyAddr := strings.TrimPrefix(runner.Runs()[0].Argv[2], "socks5h://")
+	assert.NotEqual(t, "127.0.0.1:0", proxyAddr)
+
+	dialer, err := xproxy.SOCKS5("tcp", proxyAddr, nil, xproxy.Direct)
+	require.NoError(t, err)
+
+	c, err := dialer.Dial("tcp", target.Addr().String())
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(c)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+	c.Close()
+
+	assert.NoError(t, session.Close())
+	assert.Equal(t, []string{"1080"}, server.Forwarded())
+}
+
+func TestStartMetrics(t *testing.T) {
+	t.Parallel()
+
+	server := kubetest.NewServer(t)
+	server.Add(newTestPod(nil))
+
+	errOut := &strings.Builder{}
+	streams := genericclioptions.IOStreams{In: strings.NewReader(""), Out: io.Discard, ErrOut: errOut}
+
+	config := &Config{Runner: command.NewFakeRunner(), Persist: true, GracePeriod: time.Second, MetricsAddr: "127.0.0.1:0"}
+
+	session, err := Start(context.Background(), newTestClient(t, server), config, nil, "pod/db-0", "0:5432", streams)
+	require.NoError(t, err)
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(session.Ports()[0].Local)))
+	require.NoError(t, err)
+
+	_, err = c.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	b := make([]byte, 5)
+	_, err = io.ReadFull(c, b)
+	require.NoError(t, err)
+
+	res, err := http.Get("http://" + session.MetricsAddr() + "/metrics")
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+
+	labels := `{target="db-0",namespace="db",pod="db-0",port="5432"}`
+	assert.Contains(t, string(body), "# TYPE exec_forward_bytes_sent_total counter\n")
+	assert.Contains(t, string(body), "exec_forward_bytes_sent_total"+labels+" 5\n")
+	assert.Contains(t, string(body), "exec_forward_bytes_received_total"+labels+" 5\n")
+	assert.Contains(t, string(body), "exec_forward_connections_total"+labels+" 1\n")
+	assert.Contains(t, string(body), "exec_forward_active_connections"+labels+" 1\n")
+	assert.Contains(t, string(body), "exec_forward_errors_total"+labels+" 0\n")
+
+	c.Close()
+	assert.NoError(t, session.Close())
+
+	assert.Contains(t, errOut.String(), "Stats for db-0: 5 B sent, 5 B received, 1 connections, 0 errors\n")
+}
+
+func TestStartLimits(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		annotations       map[string]string
+		targetAnnotations map[string]string
+		idleTimeout       time.Duration
+		maxDuration       time.Duration
+		traffic           bool
+
+		error string
+	}{
+		{
+			name:        "close idle sessions",
+			idleTimeout: 100 * time.Millisecond,
+			error:       "no traffic for 100ms, the session's idle timeout",
+		},
+		{
+			name:        "close sessions lasting their maximum duration",
+			idleTimeout: 100 * time.Millisecond,
+			maxDuration: 300 * time.Millisecond,
+			traffic:     true,
+			error:       "session reached its maximum duration of 300ms",
+		},
+		{
+			name:        "lower the idle timeout to the pod's",
+			annotations: map[string]string{annotation.IdleTimeout: "100ms"},
+			idleTimeout: time.Hour,
+			error:       "no traffic for 100ms, the session's idle timeout",
+		},
+		{
+			name:              "lower the idle timeout to another target's pod",
+			targetAnnotations: map[string]string{annotation.IdleTimeout: "100ms"},
+			idleTimeout:       time.Hour,
+			error:             "no traffic for 100ms, the session's idle timeout",
+		},
+		{
+			name:        "enforce the pod's maximum duration",
+			annotations: map[string]string{annotation.MaxDuration: "100ms"},
+			error:       "session reached its maximum duration of 100ms",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			annotations := map[string]string{annotation.Teardown: `[{"command": ["revoke-token"]}]`}
+			for k, v := range tc.annotations {
+				annotations[k] = v
+			}
+
+			server := kubetest.NewServer(t)
+			server.Add(newTestPod(annotations))
+
+			runner := command.NewFakeRunner()
+			config := &Config{
+				Runner:      runner,
+				Persist:     true,
+				GracePeriod: time.Second,
+				IdleTimeout: tc.idleTimeout,
+				MaxDuration: tc.maxDuration,
+			}
+
+			if tc.targetAnnotations != nil {
+				cache := newTestPod(tc.targetAnnotations)
+				cache.Name = "redis-0"
+				server.Add(cache)
+
+				config.Targets = []Target{{Name: "cache", Resource: "pod/redis-0", Port: "0:6379"}}
+			}
+
+			session, err := Start(context.Background(), newTestClient(t, server), config, nil, "pod/db-0", "0:5432", genericclioptions.NewTestIOStreamsDiscard())
+			require.NoError(t, err)
+
+			if tc.traffic {
+				c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(session.Ports()[0].Local)))
+				require.NoError(t, err)
+
+				t.Cleanup(func() { c.Close() })
+
+				go func() {
+					b := make([]byte, 4)
+
+					for {
+						if _, err := c.Write([]byte("ping")); err != nil {
+							return
+						}
+
+						if _, err := io.ReadFull(c, b); err != nil {
+							return
+						}
+
+						time.Sleep(10 * time.Millisecond)
+					}
+				}()
+			}
+
+			err = session.Wait()
+			assert.EqualError(t, err, tc.error)
+			assert.Equal(t, ExitCodeExpired, ExitCode(err))
+
+			require.Len(t, runner.Runs(), 1)
+			assert.Equal(t, []string{"revoke-token"}, runner.Runs()[0].Argv)
+		})
+	}
+}
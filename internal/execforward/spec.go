@@ -0,0 +1,88 @@
+package execforward
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Spec is a session specification file, listing the targets forwarded to and the commands run over them. Commands
+// follow the schema of the annotations of the same name, and override them, see annotation.Merge.
+type Spec struct {
+	Targets     []Target                             `json:"targets,omitempty"`
+	Args        command.Args                         `json:"args,omitempty"`
+	PreConnect  command.Commands                     `json:"pre-connect,omitempty"`
+	PostConnect command.Commands                     `json:"post-connect,omitempty"`
+	Command     *command.Command                     `json:"command,omitempty"`
+	Teardown    command.Commands                     `json:"teardown,omitempty"`
+	Permissions []authorizationv1.ResourceAttributes `json:"permissions,omitempty"`
+}
+
+// LoadSpec reads the session specification at path, in YAML or JSON.
+func LoadSpec(path string) (*Spec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &Spec{}
+
+	if err := yaml.UnmarshalStrict(b, spec); err != nil {
+		return nil, fmt.Errorf("parsing session spec %s: %w", path, err)
+	}
+
+	for i, t := range spec.Targets {
+		if t.Resource == "" || t.Port == "" {
+			return nil, fmt.Errorf("parsing session spec %s: target %d requires a resource and a port", path, i)
+		}
+	}
+
+	return spec, nil
+}
+
+// Annotations returns the commands declared by the spec as the annotations they override.
+func (s *Spec) Annotations() (map[string]string, error) {
+	values := map[string]interface{}{}
+
+	if s.Args != nil {
+		values[annotation.Args] = s.Args
+	}
+
+	if s.PreConnect != nil {
+		values[annotation.PreConnect] = s.PreConnect
+	}
+
+	if s.PostConnect != nil {
+		values[annotation.PostConnect] = s.PostConnect
+	}
+
+	if s.Command != nil {
+		values[annotation.Command] = s.Command
+	}
+
+	if s.Teardown != nil {
+		values[annotation.Teardown] = s.Teardown
+	}
+
+	if s.Permissions != nil {
+		values[annotation.Permissions] = s.Permissions
+	}
+
+	annotations := map[string]string{}
+
+	for key, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		annotations[key] = string(b)
+	}
+
+	return annotations, nil
+}
@@ -0,0 +1,85 @@
+package execforward
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+)
+
+func TestLoadSpec(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		spec string
+
+		expected    []Target
+		annotations map[string]string
+		error       bool
+	}{
+		{
+			name: "targets",
+			spec: "targets:\n- resource: svc/db\n  port: postgres\n- name: cache\n  resource: svc/redis\n  port: \"6379\"\n",
+			expected: []Target{
+				{Resource: "svc/db", Port: "postgres"},
+				{Name: "cache", Resource: "svc/redis", Port: "6379"},
+			},
+		},
+		{
+			name: "commands",
+			spec: "args:\n  username: admin\npre-connect:\n- id: token\n  command: [generate-token, \"{{.Args.username}}\"]\n" +
+				"command:\n  command: [psql, \"{{.Outputs.token}}\"]\n",
+			annotations: map[string]string{
+				annotation.Args:       `{"username":"admin"}`,
+				annotation.PreConnect: `[{"id":"token","command":["generate-token","{{.Args.username}}"],"interactive":false,"name":""}]`,
+				annotation.Command:    `{"id":"","command":["psql","{{.Outputs.token}}"],"interactive":false,"name":""}`,
+			},
+		},
+		{
+			name:  "missing port",
+			spec:  "targets:\n- resource: svc/db\n",
+			error: true,
+		},
+		{
+			name:  "unknown field",
+			spec:  "targets:\n- resource: svc/db\n  port: postgres\n  remote: 5432\n",
+			error: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "session.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.spec), 0o600))
+
+			spec, err := LoadSpec(path)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, spec.Targets)
+
+			annotations, err := spec.Annotations()
+			require.NoError(t, err)
+
+			if tc.annotations == nil {
+				assert.Empty(t, annotations)
+			} else {
+				assert.Equal(t, tc.annotations, annotations)
+			}
+		})
+	}
+}
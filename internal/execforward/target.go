@@ -0,0 +1,87 @@
+package execforward
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target is a resource forwarded to by a session.
+type Target struct {
+	// Name identifies the target in command templates, e.g., {{.Targets.db.LocalPort}}. It defaults to the name of the
+	// resource.
+	Name string `json:"name,omitempty"`
+	// Resource is the resource forwarded to, e.g., svc/db.
+	Resource string `json:"resource"`
+	// Port is the port mapping of the target, e.g., 5432 or 0:postgres.
+	Port string `json:"port"`
+	// Context is the kubeconfig context the resource is found in, the session's when empty.
+	Context string `json:"context,omitempty"`
+	// Namespace is the namespace the resource is found in. When empty, it is the session's namespace, or the default
+	// namespace of the target's context when it differs from the session's.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ParseTarget parses a target in the [name=]TYPE/NAME:PORT[,context=CONTEXT][,namespace=NAMESPACE] format, e.g.,
+// svc/db:postgres or replica=svc/db:0:5432,context=eu-west.
+func ParseTarget(s string) (Target, error) {
+	t := Target{}
+
+	options := strings.Split(s, ",")
+	s = options[0]
+
+	for _, o := range options[1:] {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return Target{}, fmt.Errorf("target option %q must be in key=value format", o)
+		}
+
+		switch kv[0] {
+		case "context":
+			t.Context = kv[1]
+		case "namespace":
+			t.Namespace = kv[1]
+		default:
+			return Target{}, fmt.Errorf("unknown target option %q, expected context or namespace", kv[0])
+		}
+	}
+
+	if i := strings.Index(s, "="); i >= 0 && i < strings.Index(s, "/") {
+		t.Name, s = s[:i], s[i+1:]
+	}
+
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || !strings.Contains(parts[0], "/") || parts[1] == "" {
+		return Target{}, fmt.Errorf("target %q must be in [name=]TYPE/NAME:PORT format", s)
+	}
+
+	t.Resource, t.Port = parts[0], parts[1]
+
+	return t, nil
+}
+
+// name returns the name of the target, defaulting to the name of its resource.
+func (t Target) name() string {
+	if t.Name != "" {
+		return t.Name
+	}
+
+	parts := strings.SplitN(t.Resource, "/", 2)
+
+	return parts[len(parts)-1]
+}
+
+// validateTargets returns an error when targets share a name, since their ports could not be told apart.
+func validateTargets(targets []Target) error {
+	names := map[string]bool{}
+
+	for _, t := range targets {
+		name := t.name()
+		if names[name] {
+			return fmt.Errorf("targets must have distinct names, %q is used twice", name)
+		}
+
+		names[name] = true
+	}
+
+	return nil
+}
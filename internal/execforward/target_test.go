@@ -0,0 +1,79 @@
+package execforward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTarget(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		target string
+
+		expected Target
+		error    bool
+	}{
+		{
+			name:     "resource and port",
+			target:   "svc/db:postgres",
+			expected: Target{Resource: "svc/db", Port: "postgres"},
+		},
+		{
+			name:     "named target with a port mapping",
+			target:   "cache=svc/redis:0:6379",
+			expected: Target{Name: "cache", Resource: "svc/redis", Port: "0:6379"},
+		},
+		{
+			name:     "target in another context and namespace",
+			target:   "replica=svc/db:5432,context=eu-west,namespace=db",
+			expected: Target{Name: "replica", Resource: "svc/db", Port: "5432", Context: "eu-west", Namespace: "db"},
+		},
+		{
+			name:   "unknown option",
+			target: "svc/db:5432,cluster=eu-west",
+			error:  true,
+		},
+		{
+			name:   "missing port",
+			target: "svc/db",
+			error:  true,
+		},
+		{
+			name:   "missing type",
+			target: "db:5432",
+			error:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			target, err := ParseTarget(tc.target)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, target)
+		})
+	}
+}
+
+func TestValidateTargets(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateTargets([]Target{{Resource: "svc/db"}, {Resource: "svc/redis"}}))
+	assert.NoError(t, validateTargets([]Target{{Resource: "svc/db"}, {Name: "replica", Resource: "pod/db"}}))
+	assert.Error(t, validateTargets([]Target{{Resource: "svc/db"}, {Resource: "pod/db"}}))
+}
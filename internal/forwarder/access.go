@@ -0,0 +1,134 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Denial is a single permission rejected by the Kubernetes API during an access check.
+type Denial struct {
+	Attributes authorizationv1.ResourceAttributes
+	Reason     string
+}
+
+// String returns the denial in a human readable format, e.g., `create pods/portforward "db-0" in namespace "db"`.
+func (d Denial) String() string {
+	a := d.Attributes
+
+	resource := a.Resource
+	if a.Group != "" {
+		resource = fmt.Sprintf("%s.%s", resource, a.Group)
+	}
+
+	if a.Subresource != "" {
+		resource = fmt.Sprintf("%s/%s", resource, a.Subresource)
+	}
+
+	str := fmt.Sprintf("%s %s", a.Verb, resource)
+
+	if a.Name != "" {
+		str = fmt.Sprintf("%s %q", str, a.Name)
+	}
+
+	if a.Namespace != "" {
+		str = fmt.Sprintf("%s in namespace %q", str, a.Namespace)
+	}
+
+	if d.Reason != "" {
+		str = fmt.Sprintf("%s: %s", str, d.Reason)
+	}
+
+	return str
+}
+
+// AccessDeniedError is returned when the current user lacks permissions required to forward to a pod or run its
+// commands.
+type AccessDeniedError struct {
+	Denied []Denial
+}
+
+// Error summarizes all denied permissions.
+func (e *AccessDeniedError) Error() string {
+	lines := []string{"insufficient permissions, ask a cluster administrator to grant the following:"}
+
+	for _, d := range e.Denied {
+		lines = append(lines, fmt.Sprintf("  - %s", d))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// CheckAccess verifies, using SelfSubjectAccessReviews, that the current user is able to get and port-forward to the
+// configured pod as well as perform any additional actions required by the pod's commands. Port-forwarding is checked
+// with the verbs the client's transport requests pods/portforward with, any of which is sufficient. Additional
+// permissions without a namespace are checked in the pod's namespace. An AccessDeniedError lists every denied
+// permission.
+func (c Client) CheckAccess(ctx context.Context, config *Config, required []authorizationv1.ResourceAttributes) error {
+	// Each entry lists alternative permissions, only the last of which is reported when all of them are denied.
+	checks := [][]authorizationv1.ResourceAttributes{
+		{{Verb: "get", Resource: "pods", Name: config.Pod.Name}},
+	}
+
+	portForward := []authorizationv1.ResourceAttributes{}
+	for _, verb := range c.Transport.portForwardVerbs() {
+		portForward = append(portForward, authorizationv1.ResourceAttributes{Verb: verb, Resource: "pods", Subresource: "portforward", Name: config.Pod.Name})
+	}
+
+	checks = append(checks, portForward)
+
+	for _, a := range required {
+		checks = append(checks, []authorizationv1.ResourceAttributes{a})
+	}
+
+	denied := []Denial{}
+
+	for _, alternatives := range checks {
+		var denial *Denial
+
+		for _, a := range alternatives {
+			a := a
+
+			if a.Namespace == "" {
+				a.Namespace = config.Pod.Namespace
+			}
+
+			review, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &a},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				// Access reviews may themselves be restricted, in which case the check is skipped rather than blocking users
+				// that are otherwise allowed to forward.
+				if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+					fmt.Fprintf(c.streams.ErrOut, "Unable to verify permissions, skipping access check: %v\n", err)
+
+					return nil
+				}
+
+				return fmt.Errorf("checking permissions: %w", err)
+			}
+
+			if review.Status.Allowed {
+				denial = nil
+
+				break
+			}
+
+			denial = &Denial{Attributes: a, Reason: review.Status.Reason}
+		}
+
+		if denial != nil {
+			denied = append(denied, *denial)
+		}
+	}
+
+	if len(denied) > 0 {
+		return &AccessDeniedError{Denied: denied}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestClientCheckAccess(t *testing.T) {
+	t.Parallel()
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db"}}
+
+	cases := []struct {
+		name string
+
+		transport Transport
+		required  []authorizationv1.ResourceAttributes
+		allow     func(a *authorizationv1.ResourceAttributes) bool
+		err       error
+
+		denied  []Denial
+		message string
+		error   bool
+	}{
+		{
+			name:  "allowed",
+			allow: func(a *authorizationv1.ResourceAttributes) bool { return true },
+		},
+		{
+			name: "portforward denied",
+			allow: func(a *authorizationv1.ResourceAttributes) bool {
+				return a.Subresource != "portforward"
+			},
+			denied: []Denial{
+				{
+					Attributes: authorizationv1.ResourceAttributes{Namespace: "db", Verb: "create", Resource: "pods", Subresource: "portforward", Name: "db-0"},
+					Reason:     "denied",
+				},
+			},
+			message: "insufficient permissions, ask a cluster administrator to grant the following:\n" +
+				`  - create pods/portforward "db-0" in namespace "db": denied`,
+		},
+		{
+			name:      "websocket portforward denied",
+			transport: TransportWebSocket,
+			allow: func(a *authorizationv1.ResourceAttributes) bool {
+				return a.Subresource != "portforward" || a.Verb == "create"
+			},
+			denied: []Denial{
+				{
+					Attributes: authorizationv1.ResourceAttributes{Namespace: "db", Verb: "get", Resource: "pods", Subresource: "portforward", Name: "db-0"},
+					Reason:     "denied",
+				},
+			},
+			message: "insufficient permissions, ask a cluster administrator to grant the following:\n" +
+				`  - get pods/portforward "db-0" in namespace "db": denied`,
+		},
+		{
+			name:      "auto portforward allowed with spdy",
+			transport: TransportAuto,
+			allow: func(a *authorizationv1.ResourceAttributes) bool {
+				return a.Subresource != "portforward" || a.Verb == "create"
+			},
+		},
+		{
+			name:      "auto portforward allowed with websocket",
+			transport: TransportAuto,
+			allow: func(a *authorizationv1.ResourceAttributes) bool {
+				return a.Subresource != "portforward" || a.Verb == "get"
+			},
+		},
+		{
+			name:      "auto portforward denied",
+			transport: TransportAuto,
+			allow: func(a *authorizationv1.ResourceAttributes) bool {
+				return a.Subresource != "portforward"
+			},
+			denied: []Denial{
+				{
+					Attributes: authorizationv1.ResourceAttributes{Namespace: "db", Verb: "create", Resource: "pods", Subresource: "portforward", Name: "db-0"},
+					Reason:     "denied",
+				},
+			},
+			message: "insufficient permissions, ask a cluster administrator to grant the following:\n" +
+				`  - create pods/portforward "db-0" in namespace "db": denied`,
+		},
+		{
+			name: "required permissions denied",
+			required: []authorizationv1.ResourceAttributes{
+				{Verb: "get", Resource: "secrets", Name: "db-creds"},
+				{Verb: "get", Group: "apps", Resource: "deployments", Namespace: "other"},
+			},
+			allow: func(a *authorizationv1.ResourceAttributes) bool {
+				return a.Resource == "pods"
+			},
+			denied: []Denial{
+				{
+					Attributes: authorizationv1.ResourceAttributes{Namespace: "db", Verb: "get", Resource: "secrets", Name: "db-creds"},
+					Reason:     "denied",
+				},
+				{
+					Attributes: authorizationv1.ResourceAttributes{Namespace: "other", Verb: "get", Group: "apps", Resource: "deployments"},
+					Reason:     "denied",
+				},
+			},
+			message: "insufficient permissions, ask a cluster administrator to grant the following:\n" +
+				`  - get secrets "db-creds" in namespace "db": denied` + "\n" +
+				`  - get deployments.apps in namespace "other": denied`,
+		},
+		{
+			name: "access reviews forbidden",
+			err:  apierrors.NewForbidden(schema.GroupResource{Group: "authorization.k8s.io", Resource: "selfsubjectaccessreviews"}, "", errors.New("forbidden")),
+		},
+		{
+			name:  "api error",
+			err:   errors.New("connection refused"),
+			error: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if tc.err != nil {
+					return true, nil, tc.err
+				}
+
+				review, ok := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				require.True(t, ok)
+
+				review.Status.Allowed = tc.allow(review.Spec.ResourceAttributes)
+				if !review.Status.Allowed {
+					review.Status.Reason = "denied"
+				}
+
+				return true, review, nil
+			})
+
+			client := NewClient(0, genericclioptions.NewTestIOStreamsDiscard())
+			client.clientset = clientset
+			client.Transport = tc.transport
+
+			err := client.CheckAccess(context.Background(), &Config{Pod: pod}, tc.required)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			if tc.denied == nil {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			var denied *AccessDeniedError
+
+			require.ErrorAs(t, err, &denied)
+			assert.Equal(t, tc.denied, denied.Denied)
+			assert.EqualError(t, err, tc.message)
+		})
+	}
+}
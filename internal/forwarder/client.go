@@ -1,11 +1,13 @@
 package forwarder
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/takescoop/kubectl-exec-forward/internal/attachablepod"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -13,12 +15,15 @@ import (
 
 // Client interfaces with Kubernetes to facilitate a port-forwarding tunnel as well as fetch information about the forwarding target.
 type Client struct {
-	clientset  *kubernetes.Clientset
+	clientset  kubernetes.Interface
 	restConfig *rest.Config
 
 	Namespace string
+	// Transport is the protocol port-forwarding connections are made with, SPDY when empty.
+	Transport Transport
 
-	AttachablePodForObjectFn func(resource string, namespace string, timeout time.Duration) (interface{}, *v1.Pod, error)
+	AttachablePodForObjectFn func(ctx context.Context, resource string, namespace string, timeout time.Duration) (interface{}, *v1.Pod, error)
+	ObjectFn                 func(resource string, namespace string) (runtime.Object, error)
 
 	timeout time.Duration
 	streams genericclioptions.IOStreams
@@ -36,6 +41,12 @@ func NewClient(timeout time.Duration, streams genericclioptions.IOStreams) *Clie
 
 // Init instantiates a Kubernetes client and rest configuration for the forwarding client.
 func (c *Client) Init(getter genericclioptions.RESTClientGetter, version string) error {
+	return c.InitWithClientset(getter, nil, version)
+}
+
+// InitWithClientset is like Init, but sends API requests with the passed clientset rather than one created from the
+// getter's rest configuration, unless it is nil.
+func (c *Client) InitWithClientset(getter genericclioptions.RESTClientGetter, clientset kubernetes.Interface, version string) error {
 	userAgent := fmt.Sprintf("kubectl-exec-forward/%s", version)
 
 	getter = userAgentGetter{
@@ -43,7 +54,11 @@ func (c *Client) Init(getter genericclioptions.RESTClientGetter, version string)
 		userAgent:        userAgent,
 	}
 
-	c.AttachablePodForObjectFn = attachablepod.New(getter).Get
+	pods := attachablepod.New(getter)
+	pods.Progress = c.streams.ErrOut
+
+	c.AttachablePodForObjectFn = pods.GetContext
+	c.ObjectFn = pods.Object
 
 	ns, _, err := getter.ToRawKubeConfigLoader().Namespace()
 	if err != nil {
@@ -59,6 +74,12 @@ func (c *Client) Init(getter genericclioptions.RESTClientGetter, version string)
 
 	c.restConfig = rc
 
+	if clientset != nil {
+		c.clientset = clientset
+
+		return nil
+	}
+
 	cs, err := kubernetes.NewForConfig(rc)
 	if err != nil {
 		return err
@@ -68,3 +89,12 @@ func (c *Client) Init(getter genericclioptions.RESTClientGetter, version string)
 
 	return nil
 }
+
+// Cluster returns the address of the Kubernetes API server the client is connected to.
+func (c *Client) Cluster() string {
+	if c.restConfig == nil {
+		return ""
+	}
+
+	return c.restConfig.Host
+}
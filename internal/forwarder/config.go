@@ -1,15 +1,22 @@
 package forwarder
 
 import (
+	"context"
 	"strconv"
 
+	"github.com/takescoop/kubectl-exec-forward/internal/attachablepod"
+	"github.com/takescoop/kubectl-exec-forward/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	corev1 "k8s.io/api/core/v1"
 )
 
 // Config contains the information required to satisfy a call to Forward.
 type Config struct {
-	Pod  *corev1.Pod
-	Port string
+	Pod       *corev1.Pod
+	Port      string
+	Container string
+	// Metrics accounts for the traffic of the forwarding connection. When nil, traffic is not accounted for.
+	Metrics *Metrics
 }
 
 // GetLocalPort returns the local ports from the Config port mapping.
@@ -24,20 +31,49 @@ func (c Config) GetLocalPort() (port int, err error) {
 	return int(local), nil
 }
 
-// NewConfig interacts with the Kubernetes API to find a pod and ports suitable for forwarding.
-func (c Client) NewConfig(resource string, portMap string) (*Config, error) {
-	obj, pod, err := c.AttachablePodForObjectFn(resource, c.Namespace, c.timeout)
+// NewConfig interacts with the Kubernetes API to find a pod and ports suitable for forwarding. When container is not
+// empty, named ports are resolved against that container only. Resolving the pod and its ports is traced as children of
+// the span in ctx.
+func (c Client) NewConfig(ctx context.Context, resource string, portMap string, container string) (*Config, error) {
+	spanCtx, span := tracing.Start(ctx, "resolve pod",
+		attribute.String("exec_forward.resource", resource),
+		attribute.String("k8s.namespace.name", c.Namespace),
+	)
+
+	obj, pod, err := c.AttachablePodForObjectFn(spanCtx, resource, c.Namespace, c.timeout)
+	if err == nil {
+		span.SetAttributes(attribute.String("k8s.pod.name", pod.Name))
+	}
+
+	tracing.End(span, err)
+
 	if err != nil {
 		return nil, err
 	}
 
-	port, err := c.translatePorts(obj, pod, portMap)
+	_, span = tracing.Start(ctx, "translate ports", attribute.String("exec_forward.port", portMap))
+
+	port, err := c.translatePorts(obj, pod, portMap, container)
+	tracing.End(span, err)
+
 	if err != nil {
 		return nil, err
 	}
 
+	// Running pods are attachable even when some of their containers cannot start, unless the forwarded port is
+	// served by one of them.
+	owner := container
+	if owner == "" {
+		owner = portContainer(*pod, port)
+	}
+
+	if err := attachablepod.ContainerError(pod, owner); err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		Pod:  pod,
-		Port: port,
+		Pod:       pod,
+		Port:      port,
+		Container: container,
 	}, nil
 }
@@ -1,9 +1,14 @@
 package forwarder
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestGetLocalPort(t *testing.T) {
@@ -29,3 +34,75 @@ func TestGetLocalPort(t *testing.T) {
 		assert.Equal(t, 8080, actual)
 	})
 }
+
+func TestClientNewConfigContainerStatus(t *testing.T) {
+	t.Parallel()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "postgres", Ports: []corev1.ContainerPort{{ContainerPort: 5432}}},
+				{Name: "exporter", Ports: []corev1.ContainerPort{{ContainerPort: 9187}}},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "postgres", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "exporter", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			},
+		},
+	}
+
+	cases := []struct {
+		name string
+
+		port      string
+		container string
+
+		error string
+	}{
+		{
+			name: "port of a running container",
+			port: "5432",
+		},
+		{
+			name:  "port of a crash looping container",
+			port:  "9187",
+			error: "pod db-0 cannot start: container exporter is CrashLoopBackOff",
+		},
+		{
+			name:      "crash looping container",
+			port:      "8080",
+			container: "exporter",
+			error:     "pod db-0 cannot start: container exporter is CrashLoopBackOff",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := Client{
+				Namespace: "db",
+				AttachablePodForObjectFn: func(ctx context.Context, resource string, namespace string, timeout time.Duration) (interface{}, *corev1.Pod, error) {
+					return pod, pod, nil
+				},
+			}
+
+			config, err := client.NewConfig(context.Background(), "pod/db-0", tc.port, tc.container)
+
+			if tc.error != "" {
+				assert.EqualError(t, err, tc.error)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.port, config.Port)
+		})
+	}
+}
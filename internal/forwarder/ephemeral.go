@@ -0,0 +1,141 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ephemeralPollInterval is how often the pod is checked while waiting for an ephemeral container to start.
+const ephemeralPollInterval = 500 * time.Millisecond
+
+// EphemeralContainer configures an ephemeral container added to the forwarded pod, for pods whose images lack the
+// tooling needed to reach a port. It shares the pod's network, so connections are forwarded to it like to any other
+// container of the pod.
+type EphemeralContainer struct {
+	// Image is the image of the container.
+	Image string
+	// Relay is the address connections to the forwarded port are relayed to with socat, e.g., "db.internal:5432" for a
+	// database only reachable from the pod's network. When empty, the image's own command runs, e.g., for commands
+	// executed in the container with kubectl exec.
+	Relay string
+	// Timeout is how long to wait for the container to start.
+	Timeout time.Duration
+}
+
+// EphemeralContainerAccess returns the permission required to add ephemeral containers to the configured pod.
+func EphemeralContainerAccess(config *Config) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Verb:        "update",
+		Resource:    "pods",
+		Subresource: "ephemeralcontainers",
+		Name:        config.Pod.Name,
+	}
+}
+
+// AddEphemeralContainer adds an ephemeral container to the configured pod through the ephemeralcontainers subresource
+// and waits for it to start. The configuration is updated with the resulting pod, and the container's name is returned.
+//
+// Ephemeral containers cannot be removed, so relays keep listening on the forwarded port once the session has ended. A
+// relay still running with the same image and command is therefore reused rather than added again, which is reported
+// by returning false.
+func (c Client) AddEphemeralContainer(ctx context.Context, config *Config, ec EphemeralContainer) (string, bool, error) {
+	name := fmt.Sprintf("exec-forward-%s", utilrand.String(5))
+
+	container := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:  name,
+			Image: ec.Image,
+		},
+		TargetContainerName: config.Container,
+	}
+
+	if ec.Relay != "" {
+		_, remote := splitPort(config.Port)
+
+		container.Command = []string{
+			"socat",
+			fmt.Sprintf("tcp-listen:%s,fork,reuseaddr", remote),
+			fmt.Sprintf("tcp-connect:%s", ec.Relay),
+		}
+
+		if running := runningRelay(config.Pod, container); running != "" {
+			return running, false, nil
+		}
+	}
+
+	pod := config.Pod.DeepCopy()
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, container)
+
+	pods := c.clientset.CoreV1().Pods(pod.Namespace)
+
+	if _, err := pods.UpdateEphemeralContainers(ctx, pod.Name, pod, metav1.UpdateOptions{}); err != nil {
+		return "", false, fmt.Errorf("adding ephemeral container to pod %s: %w", pod.Name, err)
+	}
+
+	err := wait.PollImmediateWithContext(ctx, ephemeralPollInterval, ec.Timeout, func(ctx context.Context) (bool, error) {
+		p, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		for _, status := range p.Status.EphemeralContainerStatuses {
+			if status.Name != name {
+				continue
+			}
+
+			if t := status.State.Terminated; t != nil {
+				return false, fmt.Errorf("ephemeral container %s exited: %s", name, t.Reason)
+			}
+
+			if status.State.Running != nil {
+				config.Pod = p
+
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		if errors.Is(err, wait.ErrWaitTimeout) {
+			return "", false, fmt.Errorf("timed out waiting for ephemeral container %s to start", name)
+		}
+
+		return "", false, err
+	}
+
+	return name, true, nil
+}
+
+// runningRelay returns the name of a running ephemeral container of the pod relaying like the passed container, or an
+// empty string when there is none.
+func runningRelay(pod *corev1.Pod, container corev1.EphemeralContainer) string {
+	running := map[string]bool{}
+
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		running[status.Name] = status.State.Running != nil
+	}
+
+	for _, c := range pod.Spec.EphemeralContainers {
+		if !running[c.Name] || !strings.HasPrefix(c.Name, "exec-forward-") {
+			continue
+		}
+
+		if c.Image == container.Image && c.TargetContainerName == container.TargetContainerName &&
+			reflect.DeepEqual(c.Command, container.Command) {
+			return c.Name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,158 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestClientAddEphemeralContainer(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		relay    string
+		existing *corev1.ContainerState
+		state    corev1.ContainerState
+		err      error
+
+		command []string
+		reused  bool
+		error   bool
+	}{
+		{
+			name:    "relay",
+			relay:   "127.0.0.1:5432",
+			state:   corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			command: []string{"socat", "tcp-listen:15432,fork,reuseaddr", "tcp-connect:127.0.0.1:5432"},
+		},
+		{
+			name:     "reuse a running relay",
+			relay:    "127.0.0.1:5432",
+			existing: &corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			err:      errors.New("unexpected update"),
+			command:  []string{"socat", "tcp-listen:15432,fork,reuseaddr", "tcp-connect:127.0.0.1:5432"},
+			reused:   true,
+		},
+		{
+			name:     "replace an exited relay",
+			relay:    "127.0.0.1:5432",
+			existing: &corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error"}},
+			state:    corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			command:  []string{"socat", "tcp-listen:15432,fork,reuseaddr", "tcp-connect:127.0.0.1:5432"},
+		},
+		{
+			name:  "image command",
+			state: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		},
+		{
+			name:  "exited",
+			state: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error"}},
+			error: true,
+		},
+		{
+			name:  "not started",
+			error: true,
+		},
+		{
+			name:  "update rejected",
+			err:   errors.New("ephemeral containers are disabled"),
+			error: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}},
+			}
+
+			if tc.existing != nil {
+				pod.Spec.EphemeralContainers = []corev1.EphemeralContainer{{
+					EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+						Name:    "exec-forward-abcde",
+						Image:   "alpine/socat",
+						Command: []string{"socat", "tcp-listen:15432,fork,reuseaddr", "tcp-connect:127.0.0.1:5432"},
+					},
+					TargetContainerName: "postgres",
+				}}
+				pod.Status.EphemeralContainerStatuses = []corev1.ContainerStatus{{Name: "exec-forward-abcde", State: *tc.existing}}
+			}
+
+			clientset := fake.NewSimpleClientset(pod)
+			clientset.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "ephemeralcontainers" {
+					return false, nil, nil
+				}
+
+				if tc.err != nil {
+					return true, nil, tc.err
+				}
+
+				updated, ok := action.(k8stesting.UpdateAction).GetObject().(*corev1.Pod)
+				require.True(t, ok)
+
+				for _, c := range updated.Spec.EphemeralContainers[len(updated.Status.EphemeralContainerStatuses):] {
+					updated.Status.EphemeralContainerStatuses = append(updated.Status.EphemeralContainerStatuses, corev1.ContainerStatus{
+						Name:  c.Name,
+						State: tc.state,
+					})
+				}
+
+				require.NoError(t, clientset.Tracker().Update(corev1.SchemeGroupVersion.WithResource("pods"), updated, updated.Namespace))
+
+				return true, updated, nil
+			})
+
+			client := NewClient(0, genericclioptions.NewTestIOStreamsDiscard())
+			client.clientset = clientset
+
+			config := &Config{Pod: pod, Port: "0:15432", Container: "postgres"}
+
+			name, added, err := client.AddEphemeralContainer(context.Background(), config, EphemeralContainer{
+				Image:   "alpine/socat",
+				Relay:   tc.relay,
+				Timeout: time.Second,
+			})
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, !tc.reused, added)
+
+			expected := 1
+			if tc.existing != nil && !tc.reused {
+				expected = 2
+			}
+
+			require.Len(t, config.Pod.Spec.EphemeralContainers, expected)
+
+			container := config.Pod.Spec.EphemeralContainers[expected-1]
+
+			assert.Equal(t, name, container.Name)
+			assert.Equal(t, "alpine/socat", container.Image)
+			assert.Equal(t, "postgres", container.TargetContainerName)
+			assert.Equal(t, tc.command, container.Command)
+		})
+	}
+}
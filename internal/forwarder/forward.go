@@ -1,10 +1,7 @@
 package forwarder
 
 import (
-	"net/http"
-
 	"k8s.io/client-go/tools/portforward"
-	"k8s.io/client-go/transport/spdy"
 )
 
 // Connection stores port-forwarding information for an open connection.
@@ -15,24 +12,27 @@ type Connection struct {
 
 // Forward creates a port-forwarding connection to the target noted by the ForwardConfig object.
 func (c Client) Forward(config *Config, readyChan chan Connection, stopChan chan struct{}) error {
-	transport, upgrader, err := spdy.RoundTripperFor(c.restConfig)
-	if err != nil {
-		return err
-	}
-
-	url := c.clientset.RESTClient().
+	url := c.clientset.CoreV1().RESTClient().
 		Post().
-		Prefix("api/v1").
 		Resource("pods").
 		Namespace(config.Pod.Namespace).
 		Name(config.Pod.Name).
 		SubResource("portforward").
 		URL()
 
-	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
+	_, remote := splitPort(config.Port)
+
+	dialer, err := c.dialer(url, remote)
+	if err != nil {
+		return err
+	}
+
+	if config.Metrics != nil {
+		dialer = &countingDialer{Dialer: dialer, metrics: config.Metrics}
+	}
 
 	openChan := make(chan struct{})
-	errChan := make(chan error)
+	errChan := make(chan error, 1)
 
 	fw, err := portforward.New(dialer, []string{config.Port}, stopChan, openChan, c.streams.Out, c.streams.ErrOut)
 	if err != nil {
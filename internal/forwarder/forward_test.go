@@ -0,0 +1,262 @@
+package forwarder
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/kubetest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestClientForward(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{"app": "db"}
+
+	objects := []runtime.Object{
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db", Labels: labels},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "postgres", Ports: []corev1.ContainerPort{{Name: "postgres", ContainerPort: 5432}}},
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "db"},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports:    []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromString("postgres")}},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "db"},
+			Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		},
+	}
+
+	cases := []struct {
+		name string
+
+		resource        string
+		port            string
+		transport       Transport
+		rejectWebSocket bool
+
+		forwarded string
+		// probed is set when the WebSocket upgrade is verified by forwarding a connection that is closed right away.
+		probed bool
+		error  bool
+	}{
+		{
+			name:      "pod",
+			resource:  "pod/db-0",
+			port:      "0:5432",
+			forwarded: "5432",
+		},
+		{
+			name:      "service",
+			resource:  "svc/db",
+			port:      "0:80",
+			forwarded: "5432",
+		},
+		{
+			name:      "deployment",
+			resource:  "deployment/db",
+			port:      "0:postgres",
+			forwarded: "5432",
+		},
+		{
+			name:      "websocket",
+			resource:  "pod/db-0",
+			port:      "0:5432",
+			transport: TransportWebSocket,
+			forwarded: "5432",
+			probed:    true,
+		},
+		{
+			name:      "auto",
+			resource:  "svc/db",
+			port:      "0:80",
+			transport: TransportAuto,
+			forwarded: "5432",
+			probed:    true,
+		},
+		{
+			name:            "auto falls back to spdy",
+			resource:        "pod/db-0",
+			port:            "0:5432",
+			transport:       TransportAuto,
+			rejectWebSocket: true,
+			forwarded:       "5432",
+		},
+		{
+			name:            "websocket rejected",
+			resource:        "pod/db-0",
+			port:            "0:5432",
+			transport:       TransportWebSocket,
+			rejectWebSocket: true,
+			error:           true,
+		},
+		{
+			name:     "missing resource",
+			resource: "svc/missing",
+			port:     "0:80",
+			error:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := kubetest.NewServer(t)
+			server.Add(objects...)
+			server.RejectWebSocket = tc.rejectWebSocket
+
+			client := NewClient(time.Second, genericclioptions.NewTestIOStreamsDiscard())
+			client.Transport = tc.transport
+
+			require.NoError(t, client.Init(NewRESTConfigGetter(server.RESTConfig(), "db"), "test"))
+
+			readyChan := make(chan Connection, 1)
+			stopChan := make(chan struct{})
+
+			defer close(stopChan)
+
+			metrics := &Metrics{}
+
+			config, err := client.NewConfig(context.Background(), tc.resource, tc.port, "")
+			if err == nil {
+				config.Metrics = metrics
+				err = client.Forward(config, readyChan, stopChan)
+			}
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			conn := <-readyChan
+
+			assert.NotZero(t, conn.Local)
+			assert.Equal(t, tc.forwarded, strconv.Itoa(conn.Remote))
+
+			assertEcho(t, conn.Local)
+
+			forwarded := []string{tc.forwarded}
+			if tc.probed {
+				forwarded = append(forwarded, tc.forwarded)
+			}
+
+			assert.Equal(t, forwarded, server.Forwarded())
+
+			stats := metrics.Stats()
+			assert.Equal(t, uint64(1), stats.Connections)
+			assert.Equal(t, uint64(len("hello")), stats.BytesSent)
+			assert.Equal(t, uint64(len("hello")), stats.BytesReceived)
+			assert.Zero(t, stats.Errors)
+		})
+	}
+}
+
+func TestClientForwardWebSocketIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The pod's server closes connections that stay idle, like databases awaiting authentication do.
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { upstream.Close() })
+
+	go func() {
+		for {
+			c, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+
+				_ = c.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+
+				b := make([]byte, 1024)
+
+				n, err := c.Read(b)
+				if err != nil {
+					return
+				}
+
+				_ = c.SetReadDeadline(time.Time{})
+				_, _ = c.Write(b[:n])
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+
+	server := kubetest.NewServer(t)
+	server.Upstream = upstream.Addr().String()
+	server.Add(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	client := NewClient(time.Second, genericclioptions.NewTestIOStreamsDiscard())
+	client.Transport = TransportWebSocket
+
+	require.NoError(t, client.Init(NewRESTConfigGetter(server.RESTConfig(), "db"), "test"))
+
+	config, err := client.NewConfig(context.Background(), "pod/db-0", "0:5432", "")
+	require.NoError(t, err)
+
+	readyChan := make(chan Connection, 1)
+	stopChan := make(chan struct{})
+
+	defer close(stopChan)
+
+	go func() {
+		_ = client.Forward(config, readyChan, stopChan)
+	}()
+
+	conn := <-readyChan
+
+	// The first connection is made once the server would have closed a connection opened along with the tunnel.
+	time.Sleep(200 * time.Millisecond)
+
+	assertEcho(t, conn.Local)
+}
+
+// assertEcho asserts that data sent to the local port is echoed back through the forwarding connection.
+func assertEcho(t *testing.T, port int) {
+	t.Helper()
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	_, err = c.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	// WebSocket connections cannot be half-closed, so the echo is read back while the connection remains open.
+	b := make([]byte, len("hello"))
+	_, err = io.ReadFull(c, b)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+}
@@ -0,0 +1,144 @@
+package forwarder
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// Metrics accounts for the traffic of a forwarding connection. It is safe for concurrent use.
+type Metrics struct {
+	bytesSent     atomic.Uint64
+	bytesReceived atomic.Uint64
+	connections   atomic.Uint64
+	active        atomic.Int64
+	errors        atomic.Uint64
+}
+
+// Stats is a snapshot of the metrics of a forwarding connection.
+type Stats struct {
+	// BytesSent is the number of bytes sent to the pod.
+	BytesSent uint64
+	// BytesReceived is the number of bytes received from the pod.
+	BytesReceived uint64
+	// Connections is the number of local connections forwarded to the pod.
+	Connections uint64
+	// Active is the number of local connections currently forwarded.
+	Active int64
+	// Errors is the number of connections the pod reported an error for, e.g., because nothing listened on the port.
+	Errors uint64
+}
+
+// Stats returns the current values of the metrics.
+func (m *Metrics) Stats() Stats {
+	return Stats{
+		BytesSent:     m.bytesSent.Load(),
+		BytesReceived: m.bytesReceived.Load(),
+		Connections:   m.connections.Load(),
+		Active:        m.active.Load(),
+		Errors:        m.errors.Load(),
+	}
+}
+
+// countingDialer records the traffic of the connections it dials in metrics.
+type countingDialer struct {
+	httpstream.Dialer
+	metrics *Metrics
+}
+
+// Dial dials a connection whose streams are counted.
+func (d *countingDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	conn, protocol, err := d.Dialer.Dial(protocols...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &countingConnection{Connection: conn, metrics: d.metrics}, protocol, nil
+}
+
+// countingConnection counts the traffic of the data streams of forwarded connections, and the errors reported on their
+// error streams.
+type countingConnection struct {
+	httpstream.Connection
+	metrics *Metrics
+}
+
+// CreateStream creates a stream that is counted according to its type.
+func (c *countingConnection) CreateStream(headers http.Header) (httpstream.Stream, error) {
+	stream, err := c.Connection.CreateStream(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	switch headers.Get(v1.StreamType) {
+	case v1.StreamTypeData:
+		c.metrics.connections.Add(1)
+		c.metrics.active.Add(1)
+
+		return &dataStream{Stream: stream, metrics: c.metrics}, nil
+	case v1.StreamTypeError:
+		return &errorStream{Stream: stream, metrics: c.metrics}, nil
+	default:
+		return stream, nil
+	}
+}
+
+// dataStream counts the bytes sent and received over a forwarded connection, which is no longer active once the pod
+// has stopped sending.
+type dataStream struct {
+	httpstream.Stream
+	metrics *Metrics
+	once    sync.Once
+}
+
+// Read counts the bytes received from the pod.
+func (s *dataStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	s.metrics.bytesReceived.Add(uint64(n))
+
+	if err != nil {
+		s.done()
+	}
+
+	return n, err
+}
+
+// Write counts the bytes sent to the pod.
+func (s *dataStream) Write(p []byte) (int, error) {
+	n, err := s.Stream.Write(p)
+	s.metrics.bytesSent.Add(uint64(n))
+
+	return n, err
+}
+
+// Reset resets the stream, which is then no longer active.
+func (s *dataStream) Reset() error {
+	s.done()
+
+	return s.Stream.Reset()
+}
+
+// done marks the forwarded connection as no longer active, once.
+func (s *dataStream) done() {
+	s.once.Do(func() { s.metrics.active.Add(-1) })
+}
+
+// errorStream counts the forwarded connections the pod reported an error for.
+type errorStream struct {
+	httpstream.Stream
+	metrics *Metrics
+	once    sync.Once
+}
+
+// Read counts an error once the pod has reported one.
+func (s *errorStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	if n > 0 {
+		s.once.Do(func() { s.metrics.errors.Add(1) })
+	}
+
+	return n, err
+}
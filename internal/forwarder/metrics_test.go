@@ -0,0 +1,119 @@
+package forwarder
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// fakeStream is a stream reading the bytes it was created with and discarding written bytes.
+type fakeStream struct {
+	io.Reader
+	headers http.Header
+}
+
+func (s *fakeStream) Write(p []byte) (int, error) { return len(p), nil }
+func (s *fakeStream) Close() error                { return nil }
+func (s *fakeStream) Reset() error                { return nil }
+func (s *fakeStream) Headers() http.Header        { return s.headers }
+func (s *fakeStream) Identifier() uint32          { return 0 }
+
+// fakeConnection creates streams reading the bytes configured for their type.
+type fakeConnection struct {
+	reads map[string]string
+}
+
+func (c *fakeConnection) CreateStream(headers http.Header) (httpstream.Stream, error) {
+	return &fakeStream{Reader: bytes.NewBufferString(c.reads[headers.Get(v1.StreamType)]), headers: headers}, nil
+}
+
+func (c *fakeConnection) Close() error                       { return nil }
+func (c *fakeConnection) CloseChan() <-chan bool             { return nil }
+func (c *fakeConnection) SetIdleTimeout(time.Duration)       {}
+func (c *fakeConnection) RemoveStreams(...httpstream.Stream) {}
+
+// fakeDialer dials fake connections.
+type fakeDialer struct {
+	conn *fakeConnection
+}
+
+func (d *fakeDialer) Dial(...string) (httpstream.Connection, string, error) {
+	return d.conn, "", nil
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		reads map[string]string
+
+		expected Stats
+	}{
+		{
+			name:  "forwarded connection",
+			reads: map[string]string{v1.StreamTypeData: "pong"},
+			expected: Stats{
+				BytesSent:     4,
+				BytesReceived: 4,
+				Connections:   1,
+			},
+		},
+		{
+			name:  "error reported by the pod",
+			reads: map[string]string{v1.StreamTypeError: "connection refused"},
+			expected: Stats{
+				BytesSent:   4,
+				Connections: 1,
+				Errors:      1,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			metrics := &Metrics{}
+			dialer := &countingDialer{Dialer: &fakeDialer{conn: &fakeConnection{reads: tc.reads}}, metrics: metrics}
+
+			conn, _, err := dialer.Dial()
+			require.NoError(t, err)
+
+			// Forwarded connections create an error stream, then a data stream, like portforward.PortForwarder does.
+			headers := http.Header{}
+			headers.Set(v1.StreamType, v1.StreamTypeError)
+
+			errStream, err := conn.CreateStream(headers)
+			require.NoError(t, err)
+
+			headers.Set(v1.StreamType, v1.StreamTypeData)
+
+			dataStream, err := conn.CreateStream(headers)
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(1), metrics.Stats().Active)
+
+			_, err = dataStream.Write([]byte("ping"))
+			require.NoError(t, err)
+
+			_, err = io.ReadAll(dataStream)
+			require.NoError(t, err)
+
+			_, err = io.ReadAll(errStream)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, metrics.Stats())
+		})
+	}
+}
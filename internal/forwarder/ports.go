@@ -13,14 +13,39 @@ import (
 	"k8s.io/kubectl/pkg/util"
 )
 
-// Translates the passed runtime object port mappings into ports that target the passed pod.
-func (c Client) translatePorts(obj interface{}, pod *corev1.Pod, port string) (string, error) {
+// Translates the passed runtime object port mappings into ports that target the passed pod. When a container is
+// passed, named ports are only looked up in that container.
+func (c Client) translatePorts(obj interface{}, pod *corev1.Pod, port string, container string) (string, error) {
+	scoped, err := scopePodToContainer(*pod, container)
+	if err != nil {
+		return "", err
+	}
+
 	switch t := obj.(type) {
 	case *corev1.Service:
-		return translateServicePortToTargetPort(port, *t, *pod)
+		return translateServicePortToTargetPort(port, *t, scoped)
 	default:
-		return convertPodNamedPortToNumber(port, *pod)
+		return convertPodNamedPortToNumber(port, scoped)
+	}
+}
+
+// scopePodToContainer returns a copy of the pod whose containers are limited to the named container, so that port
+// lookups do not match ports declared by other containers in the pod. An empty container returns the pod unchanged.
+func scopePodToContainer(pod corev1.Pod, container string) (corev1.Pod, error) {
+	if container == "" {
+		return pod, nil
+	}
+
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			scoped := *pod.DeepCopy()
+			scoped.Spec.Containers = []corev1.Container{c}
+
+			return scoped, nil
+		}
 	}
+
+	return corev1.Pod{}, fmt.Errorf("container %s not found in pod %s", container, pod.Name)
 }
 
 // splitPort splits port string which is in form of [LOCAL PORT]:REMOTE PORT
@@ -94,3 +119,23 @@ func convertPodNamedPortToNumber(port string, pod corev1.Pod) (string, error) {
 
 	return containerPortStr, nil
 }
+
+// portContainer returns the name of the container serving the remote port of the port mapping, which is the container
+// declaring it or else the pod's only container. It returns an empty string when no container is known to serve it.
+func portContainer(pod corev1.Pod, port string) string {
+	_, remotePort := splitPort(port)
+
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if strconv.Itoa(int(p.ContainerPort)) == remotePort {
+				return c.Name
+			}
+		}
+	}
+
+	if len(pod.Spec.Containers) == 1 {
+		return pod.Spec.Containers[0].Name
+	}
+
+	return ""
+}
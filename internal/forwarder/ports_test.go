@@ -156,3 +156,149 @@ func TestTranslateServicePortToTargetPort(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslatePortsContainer(t *testing.T) {
+	t.Parallel()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-pod",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "app",
+					Ports: []corev1.ContainerPort{
+						{
+							Name:          "http",
+							ContainerPort: 8080,
+						},
+					},
+				},
+				{
+					Name: "proxy",
+					Ports: []corev1.ContainerPort{
+						{
+							Name:          "http",
+							ContainerPort: 15001,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name string
+
+		obj       interface{}
+		port      string
+		container string
+
+		expected string
+		error    string
+	}{
+		{
+			name:     "first matching container without a container",
+			obj:      pod,
+			port:     "http",
+			expected: "8080",
+		},
+		{
+			name:      "named port scoped to container",
+			obj:       pod,
+			port:      "9000:http",
+			container: "proxy",
+			expected:  "9000:15001",
+		},
+		{
+			name: "service target port scoped to container",
+			obj: &corev1.Service{
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{
+						{
+							Port:       80,
+							TargetPort: intstr.FromString("http"),
+						},
+					},
+				},
+			},
+			port:      "80",
+			container: "proxy",
+			expected:  "80:15001",
+		},
+		{
+			name:      "unknown container",
+			obj:       pod,
+			port:      "http",
+			container: "exporter",
+			error:     "container exporter not found in pod my-pod",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := Client{}.translatePorts(tc.obj, pod, tc.port, tc.container)
+
+			if tc.error != "" {
+				assert.EqualError(t, err, tc.error)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestPortContainer(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		containers []corev1.Container
+		port       string
+
+		expected string
+	}{
+		{
+			name: "declared port",
+			containers: []corev1.Container{
+				{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: 8080}}},
+				{Name: "proxy", Ports: []corev1.ContainerPort{{ContainerPort: 15001}}},
+			},
+			port:     "9000:15001",
+			expected: "proxy",
+		},
+		{
+			name:       "only container",
+			containers: []corev1.Container{{Name: "app"}},
+			port:       "8080",
+			expected:   "app",
+		},
+		{
+			name:       "undeclared port",
+			containers: []corev1.Container{{Name: "app"}, {Name: "proxy"}},
+			port:       "8080",
+			expected:   "",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: tc.containers}}
+
+			assert.Equal(t, tc.expected, portContainer(pod, tc.port))
+		})
+	}
+}
@@ -1,8 +1,15 @@
 package forwarder
 
 import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // userAgentGetter is a RESTClientGetter that adds a user agent to the client.
@@ -22,3 +29,54 @@ func (r userAgentGetter) ToRESTConfig() (*rest.Config, error) {
 
 	return rc, nil
 }
+
+// restConfigGetter is a RESTClientGetter for an existing rest configuration, rather than one loaded from kubeconfig
+// files.
+type restConfigGetter struct {
+	config    *rest.Config
+	namespace string
+}
+
+// NewRESTConfigGetter returns a RESTClientGetter for the passed rest configuration, resolving resources in the passed
+// namespace, or the default namespace when empty.
+func NewRESTConfigGetter(config *rest.Config, namespace string) genericclioptions.RESTClientGetter {
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	return restConfigGetter{config: config, namespace: namespace}
+}
+
+// ToRESTConfig returns a copy of the rest configuration.
+func (g restConfigGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(g.config), nil
+}
+
+// ToDiscoveryClient returns a discovery client caching API resources in memory.
+func (g restConfigGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(g.config)
+	if err != nil {
+		return nil, err
+	}
+
+	return memory.NewMemCacheClient(dc), nil
+}
+
+// ToRESTMapper returns a mapper discovering API resources, including their short names.
+func (g restConfigGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	dc, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(dc)
+
+	return restmapper.NewShortcutExpander(mapper, dc), nil
+}
+
+// ToRawKubeConfigLoader returns an empty client configuration only providing the namespace.
+func (g restConfigGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return clientcmd.NewDefaultClientConfig(*clientcmdapi.NewConfig(), &clientcmd.ConfigOverrides{
+		Context: clientcmdapi.Context{Namespace: g.namespace},
+	})
+}
@@ -0,0 +1,50 @@
+package forwarder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+)
+
+func TestNewRESTConfigGetter(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		namespace string
+
+		expected string
+	}{
+		{
+			name:      "resolve resources in the passed namespace",
+			namespace: "db",
+			expected:  "db",
+		},
+		{
+			name:     "resolve resources in the default namespace",
+			expected: "default",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := &rest.Config{Host: "https://cluster"}
+			getter := NewRESTConfigGetter(config, tc.namespace)
+
+			ns, _, err := getter.ToRawKubeConfigLoader().Namespace()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, ns)
+
+			rc, err := getter.ToRESTConfig()
+			assert.NoError(t, err)
+			assert.Equal(t, "https://cluster", rc.Host)
+			assert.NotSame(t, config, rc)
+		})
+	}
+}
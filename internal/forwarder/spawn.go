@@ -0,0 +1,145 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultSpawnTimeout is the default time to wait for the pod of a spawned Job to be attachable.
+	DefaultSpawnTimeout = 5 * time.Minute
+
+	// DefaultJobTTL is the default time spawned Jobs may run before Kubernetes stops them.
+	DefaultJobTTL = 12 * time.Hour
+
+	// SpawnedLabel marks Jobs spawned to be forwarded to, telling them apart from scheduled ones.
+	SpawnedLabel = "exec-forward.pod.kubernetes.io/spawned"
+
+	// spawnedTTLAfterFinished is how long finished spawned Jobs are kept before Kubernetes deletes them, for Jobs that
+	// were not deleted by the plugin.
+	spawnedTTLAfterFinished = int32(60)
+
+	// spawnCleanupTimeout bounds deleting a spawned Job whose pod could not be waited for.
+	spawnCleanupTimeout = 10 * time.Second
+)
+
+// Spawn creates a Job from the template of the CronJob resource references, like kubectl create job --from does. It
+// waits within timeout, or until ctx is done, for the Job's pod to be attachable and returns the resource to forward to, along with a function
+// deleting the Job. Spawned Jobs are stopped by Kubernetes after ttl, should the plugin exit without deleting them.
+// Other resources are returned unchanged.
+func (c Client) Spawn(ctx context.Context, resource string, timeout time.Duration, ttl time.Duration) (string, func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	if !isCronJob(resource) {
+		return resource, noop, nil
+	}
+
+	obj, err := c.ObjectFn(resource, c.Namespace)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cronJob, ok := obj.(*batchv1.CronJob)
+	if !ok {
+		return resource, noop, nil
+	}
+
+	job := jobFromCronJob(cronJob)
+
+	deadline := int64(ttl.Seconds())
+	ttlAfterFinished := spawnedTTLAfterFinished
+
+	job.Spec.ActiveDeadlineSeconds = &deadline
+	job.Spec.TTLSecondsAfterFinished = &ttlAfterFinished
+
+	if job.Labels == nil {
+		job.Labels = map[string]string{}
+	}
+
+	job.Labels[SpawnedLabel] = "true"
+
+	jobs := c.clientset.BatchV1().Jobs(job.Namespace)
+
+	job, err = jobs.Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return "", nil, fmt.Errorf("creating job from %s: %w", resource, err)
+	}
+
+	fmt.Fprintf(c.streams.ErrOut, "Created job %s/%s from %s\n", job.Namespace, job.Name, resource)
+
+	cleanup := func(ctx context.Context) error {
+		propagation := metav1.DeletePropagationBackground
+
+		if err := jobs.Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return fmt.Errorf("deleting job %s: %w", job.Name, err)
+		}
+
+		fmt.Fprintf(c.streams.ErrOut, "Deleted job %s/%s\n", job.Namespace, job.Name)
+
+		return nil
+	}
+
+	spawned := fmt.Sprintf("job/%s", job.Name)
+
+	if _, _, err := c.AttachablePodForObjectFn(ctx, spawned, job.Namespace, timeout); err != nil {
+		// The Job is deleted even when ctx is done, e.g., because the wait was interrupted.
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), spawnCleanupTimeout)
+		defer cancel()
+
+		if cleanupErr := cleanup(cleanupCtx); cleanupErr != nil {
+			fmt.Fprintln(c.streams.ErrOut, cleanupErr)
+		}
+
+		return "", nil, err
+	}
+
+	return spawned, cleanup, nil
+}
+
+// isCronJob returns whether the resource is referenced by one of the names of the CronJob resource type, e.g., cj/name.
+func isCronJob(resource string) bool {
+	kind := strings.ToLower(strings.SplitN(resource, "/", 2)[0])
+
+	switch strings.TrimSuffix(kind, ".batch") {
+	case "cronjob", "cronjobs", "cj":
+		return true
+	}
+
+	return false
+}
+
+// jobFromCronJob returns a Job instantiated from the CronJob's template.
+func jobFromCronJob(cronJob *batchv1.CronJob) *batchv1.Job {
+	annotations := map[string]string{"cronjob.kubernetes.io/instantiate": "manual"}
+	for k, v := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+
+	labels := map[string]string{}
+	for k, v := range cronJob.Spec.JobTemplate.Labels {
+		labels[k] = v
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-exec-forward-", cronJob.Name),
+			Namespace:    cronJob.Namespace,
+			Annotations:  annotations,
+			Labels:       labels,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: batchv1.SchemeGroupVersion.String(),
+					Kind:       "CronJob",
+					Name:       cronJob.Name,
+					UID:        cronJob.UID,
+				},
+			},
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	}
+}
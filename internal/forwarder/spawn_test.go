@@ -0,0 +1,157 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestClientSpawn(t *testing.T) {
+	t.Parallel()
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-console", Namespace: "db", UID: "1234"},
+		Spec: batchv1.CronJobSpec{
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db-console"}},
+			},
+		},
+	}
+
+	cases := []struct {
+		name string
+
+		resource string
+		object   runtime.Object
+		podErr   error
+		// cancel cancels the spawn while the Job's pod is waited for.
+		cancel bool
+
+		expected string
+		jobs     int
+		error    bool
+	}{
+		{
+			name:     "spawn a job from a cronjob",
+			resource: "cronjob/db-console",
+			object:   cronJob,
+			expected: "job/db-console-exec-forward-1",
+			jobs:     1,
+		},
+		{
+			name:     "spawn a job from a cronjob alias",
+			resource: "cj/db-console",
+			object:   cronJob,
+			expected: "job/db-console-exec-forward-1",
+			jobs:     1,
+		},
+		{
+			name:     "leave other resources unchanged",
+			resource: "deployment/db-console",
+			expected: "deployment/db-console",
+		},
+		{
+			name:     "delete the job when its pod fails",
+			resource: "cronjob/db-console",
+			object:   cronJob,
+			podErr:   errors.New("pod failed"),
+			error:    true,
+		},
+		{
+			name:     "delete the job when the wait is canceled",
+			resource: "cronjob/db-console",
+			object:   cronJob,
+			cancel:   true,
+			error:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset()
+
+			// The fake clientset does not generate names.
+			clientset.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+				job.Name = job.GenerateName + "1"
+
+				return false, nil, nil
+			})
+
+			client := NewClient(0, genericclioptions.NewTestIOStreamsDiscard())
+			client.clientset = clientset
+			client.Namespace = "db"
+			client.ObjectFn = func(resource string, namespace string) (runtime.Object, error) {
+				return tc.object, nil
+			}
+			client.AttachablePodForObjectFn = func(ctx context.Context, resource string, namespace string, timeout time.Duration) (interface{}, *corev1.Pod, error) {
+				if tc.cancel {
+					<-ctx.Done()
+
+					return nil, nil, ctx.Err()
+				}
+
+				return nil, &corev1.Pod{}, tc.podErr
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tc.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+
+			resource, cleanup, err := client.Spawn(ctx, tc.resource, time.Hour, time.Hour)
+
+			if tc.error {
+				assert.Error(t, err)
+				assert.Empty(t, spawnedJobs(t, clientset))
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resource)
+
+			jobs := spawnedJobs(t, clientset)
+			require.Len(t, jobs, tc.jobs)
+
+			for _, job := range jobs {
+				assert.Equal(t, "true", job.Labels[SpawnedLabel])
+				assert.Equal(t, "db-console", job.Labels["app"])
+				assert.Equal(t, "manual", job.Annotations["cronjob.kubernetes.io/instantiate"])
+				assert.Equal(t, int64(3600), *job.Spec.ActiveDeadlineSeconds)
+				require.Len(t, job.OwnerReferences, 1)
+				assert.Equal(t, cronJob.UID, job.OwnerReferences[0].UID)
+			}
+
+			require.NoError(t, cleanup(context.Background()))
+			assert.Empty(t, spawnedJobs(t, clientset))
+		})
+	}
+}
+
+// spawnedJobs returns the jobs in the db namespace.
+func spawnedJobs(t *testing.T, clientset *fake.Clientset) []batchv1.Job {
+	t.Helper()
+
+	jobs, err := clientset.BatchV1().Jobs("db").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+
+	return jobs.Items
+}
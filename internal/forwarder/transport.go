@@ -0,0 +1,104 @@
+package forwarder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Transport is the protocol port-forwarding connections are made with.
+type Transport string
+
+const (
+	// TransportSPDY upgrades port-forwarding requests to SPDY, like kubectl does.
+	TransportSPDY Transport = "spdy"
+	// TransportWebSocket upgrades port-forwarding requests to WebSockets, for proxies and API servers rejecting SPDY.
+	TransportWebSocket Transport = "websocket"
+	// TransportAuto uses WebSockets, falling back to SPDY when the upgrade is rejected.
+	TransportAuto Transport = "auto"
+)
+
+// ParseTransport returns the transport with the passed name.
+func ParseTransport(name string) (Transport, error) {
+	switch t := Transport(name); t {
+	case TransportSPDY, TransportWebSocket, TransportAuto:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unsupported transport %q, must be one of websocket, spdy or auto", name)
+	}
+}
+
+// portForwardVerbs returns the verbs the transport may request the pods/portforward subresource with, any of which is
+// sufficient to forward. SPDY upgrades are POST requests authorized as create, while WebSocket upgrades are GET requests
+// authorized as get. The auto transport forwards with either, falling back to SPDY when the WebSocket upgrade is
+// forbidden, and lists SPDY last so that it is the permission reported when both are denied.
+func (t Transport) portForwardVerbs() []string {
+	switch t {
+	case TransportWebSocket:
+		return []string{"get"}
+	case TransportAuto:
+		return []string{"get", "create"}
+	default:
+		return []string{"create"}
+	}
+}
+
+// dialer returns a dialer for the portforward URL of a pod, forwarding the passed remote port with the client's
+// transport. SPDY is used by default.
+func (c Client) dialer(u *url.URL, port string) (httpstream.Dialer, error) {
+	switch c.Transport {
+	case TransportWebSocket:
+		return newWebSocketDialer(c.restConfig, u, port), nil
+	case TransportAuto:
+		fallback, err := c.spdyDialer(u)
+		if err != nil {
+			return nil, err
+		}
+
+		return &fallbackDialer{
+			primary:  newWebSocketDialer(c.restConfig, u, port),
+			fallback: fallback,
+			out:      c.streams.ErrOut,
+		}, nil
+	case TransportSPDY, "":
+		return c.spdyDialer(u)
+	default:
+		return nil, fmt.Errorf("unsupported transport %q", c.Transport)
+	}
+}
+
+// spdyDialer returns a dialer upgrading requests to SPDY.
+func (c Client) spdyDialer(u *url.URL) (httpstream.Dialer, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(c.restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", u), nil
+}
+
+// fallbackDialer dials with the primary dialer, and with the fallback dialer when the primary upgrade is rejected.
+type fallbackDialer struct {
+	primary  httpstream.Dialer
+	fallback httpstream.Dialer
+	out      io.Writer
+}
+
+// Dial opens a connection with the primary dialer, falling back on upgrade errors.
+func (d *fallbackDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	conn, protocol, err := d.primary.Dial(protocols...)
+
+	var upgradeErr *UpgradeError
+	if errors.As(err, &upgradeErr) {
+		fmt.Fprintf(d.out, "WebSocket port-forwarding unavailable, falling back to SPDY: %v\n", err)
+
+		return d.fallback.Dial(protocols...)
+	}
+
+	return conn, protocol, err
+}
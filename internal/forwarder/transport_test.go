@@ -0,0 +1,44 @@
+package forwarder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTransport(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		transport string
+
+		expected Transport
+		error    bool
+	}{
+		{name: "spdy", transport: "spdy", expected: TransportSPDY},
+		{name: "websocket", transport: "websocket", expected: TransportWebSocket},
+		{name: "auto", transport: "auto", expected: TransportAuto},
+		{name: "unsupported", transport: "http2", error: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transport, err := ParseTransport(tc.transport)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, transport)
+		})
+	}
+}
@@ -0,0 +1,513 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// wakePollInterval is how often a woken up workload is checked while waiting for its replicas to become ready.
+const wakePollInterval = time.Second
+
+// WakeHolderTTL is how long a session holds a woken up workload without renewing its hold. Holders of sessions that
+// were killed or lost their connection expire after it, so that the workload is scaled back down by the next session
+// releasing it, or by WakeGC.
+const WakeHolderTTL = 5 * time.Minute
+
+// wakeRenewInterval is how often sessions renew their hold, well within WakeHolderTTL.
+const wakeRenewInterval = WakeHolderTTL / 5
+
+// WakeHolder is a session keeping a woken up workload awake.
+type WakeHolder struct {
+	ID string `json:"id"`
+	// Since is when the session started holding the workload.
+	Since time.Time `json:"since"`
+	// Renewed is when the session last renewed its hold, Since until it has.
+	Renewed time.Time `json:"renewed"`
+}
+
+// expired returns whether the holder has not renewed its hold within WakeHolderTTL as of now.
+func (h WakeHolder) expired(now time.Time) bool {
+	renewed := h.Renewed
+	if renewed.IsZero() {
+		renewed = h.Since
+	}
+
+	return now.Sub(renewed) > WakeHolderTTL
+}
+
+// workload is a Deployment or StatefulSet whose replicas can be changed.
+type workload struct {
+	kind string
+	meta *metav1.ObjectMeta
+	// replicas is the desired number of replicas, and ready the number of ready replicas.
+	replicas int32
+	ready    int32
+
+	setReplicas func(replicas int32)
+	update      func(ctx context.Context) error
+}
+
+// Wake scales the Deployment or StatefulSet referenced by resource up when it has no replicas, to the number of
+// replicas of its wake-replicas annotation or 1, and waits within timeout for its replicas to be ready. Sessions hold
+// woken up workloads, including ones woken up by another session, so that they are not scaled down while in use. The
+// returned function releases the session's hold, and restores the original replicas once no session holds the
+// workload. Until then, the hold is renewed in the background, see WakeHolderTTL. Other resources and workloads that were
+// not woken up are left unchanged.
+func (c Client) Wake(ctx context.Context, resource string, timeout time.Duration) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	obj, err := c.ObjectFn(resource, c.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	get, ok := c.workloadGetter(obj)
+	if !ok {
+		return noop, nil
+	}
+
+	now := time.Now().UTC()
+	holder := WakeHolder{ID: newWakeHolderID(), Since: now, Renewed: now}
+	held := false
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		w, err := get(ctx)
+		if err != nil {
+			return err
+		}
+
+		holders, err := liveWakeHolders(w.meta, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// Workloads woken up by sessions whose holds have all expired are still awake, and are taken over.
+		_, woken := w.meta.Annotations[annotation.WakeOriginalReplicas]
+
+		if !woken && w.replicas > 0 {
+			held = false
+
+			return nil
+		}
+
+		if !woken {
+			target, err := wakeReplicas(w.meta)
+			if err != nil {
+				return err
+			}
+
+			metav1.SetMetaDataAnnotation(w.meta, annotation.WakeOriginalReplicas, strconv.Itoa(int(w.replicas)))
+			metav1.SetMetaDataAnnotation(w.meta, annotation.WakeWokenReplicas, strconv.Itoa(int(target)))
+			w.setReplicas(target)
+
+			fmt.Fprintf(c.streams.ErrOut, "Waking up %s/%s, scaling to %d replica(s)\n", w.kind, w.meta.Name, target)
+		}
+
+		if err := setWakeHolders(w.meta, append(holders, holder)); err != nil {
+			return err
+		}
+
+		held = true
+
+		return w.update(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("waking up %s: %w", resource, err)
+	}
+
+	if !held {
+		return noop, nil
+	}
+
+	stopRenewing := make(chan struct{})
+	renewingDone := make(chan struct{})
+
+	go func() {
+		defer close(renewingDone)
+
+		c.renewWakeHolder(get, holder, resource, stopRenewing)
+	}()
+
+	var once sync.Once
+
+	release := func(ctx context.Context) error {
+		once.Do(func() {
+			close(stopRenewing)
+			<-renewingDone
+		})
+
+		_, err := c.releaseWake(ctx, get, holder)
+
+		return err
+	}
+
+	if err := c.waitAwake(ctx, get, timeout); err != nil {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if releaseErr := release(releaseCtx); releaseErr != nil {
+			fmt.Fprintf(c.streams.ErrOut, "Unable to release %s: %v\n", resource, releaseErr)
+		}
+
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// renewWakeHolder renews the holder's hold on the workload every wakeRenewInterval until stop is closed.
+func (c Client) renewWakeHolder(get func(context.Context) (*workload, error), holder WakeHolder, resource string, stop <-chan struct{}) {
+	ticker := time.NewTicker(wakeRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), wakeRenewInterval)
+			err := c.renewWake(ctx, get, holder, time.Now().UTC())
+			cancel()
+
+			if err != nil {
+				fmt.Fprintf(c.streams.ErrOut, "Unable to renew the hold on %s: %v\n", resource, err)
+			}
+		}
+	}
+}
+
+// renewWake records that the holder still holds the workload as of now, adding it back should it have expired.
+func (c Client) renewWake(ctx context.Context, get func(context.Context) (*workload, error), holder WakeHolder, now time.Time) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		w, err := get(ctx)
+		if err != nil {
+			return err
+		}
+
+		holders, err := liveWakeHolders(w.meta, now)
+		if err != nil {
+			return err
+		}
+
+		holder.Renewed = now
+		renewed := []WakeHolder{holder}
+
+		for _, h := range holders {
+			if h.ID != holder.ID {
+				renewed = append(renewed, h)
+			}
+		}
+
+		if err := setWakeHolders(w.meta, renewed); err != nil {
+			return err
+		}
+
+		return w.update(ctx)
+	})
+}
+
+// waitAwake waits for at least one replica of the workload to be ready, reporting progress as replicas become ready.
+func (c Client) waitAwake(ctx context.Context, get func(context.Context) (*workload, error), timeout time.Duration) error {
+	last := int32(-1)
+
+	err := wait.PollImmediateWithContext(ctx, wakePollInterval, timeout, func(ctx context.Context) (bool, error) {
+		w, err := get(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		if w.ready != last {
+			fmt.Fprintf(c.streams.ErrOut, "Waiting for %s/%s to wake up: %d/%d replica(s) ready\n", w.kind, w.meta.Name, w.ready, w.replicas)
+			last = w.ready
+		}
+
+		return w.ready > 0, nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("timed out waiting for a replica to be ready after %s", timeout)
+	}
+
+	return err
+}
+
+// releaseWake removes the holder, along with expired ones, from the workload, restoring its original replicas when no
+// holder remains. Replicas changed since the workload was woken up, e.g., by someone scaling it for other reasons, are
+// left as they are. It returns whether the workload was released, rather than still being held by other sessions.
+func (c Client) releaseWake(ctx context.Context, get func(context.Context) (*workload, error), holder WakeHolder) (bool, error) {
+	released := false
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		released = false
+
+		w, err := get(ctx)
+		if err != nil {
+			return err
+		}
+
+		holders, err := liveWakeHolders(w.meta, time.Now())
+		if err != nil {
+			return err
+		}
+
+		remaining := []WakeHolder{}
+
+		for _, h := range holders {
+			if h.ID != holder.ID {
+				remaining = append(remaining, h)
+			}
+		}
+
+		if len(remaining) > 0 {
+			if err := setWakeHolders(w.meta, remaining); err != nil {
+				return err
+			}
+
+			return w.update(ctx)
+		}
+
+		released = true
+
+		original, err := strconv.ParseInt(w.meta.Annotations[annotation.WakeOriginalReplicas], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", annotation.WakeOriginalReplicas, err)
+		}
+
+		changed := false
+
+		// Workloads woken up before the woken replicas were recorded are restored regardless.
+		if raw, ok := w.meta.Annotations[annotation.WakeWokenReplicas]; ok {
+			woken, err := strconv.ParseInt(raw, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid %s annotation: %w", annotation.WakeWokenReplicas, err)
+			}
+
+			changed = int32(woken) != w.replicas
+		}
+
+		delete(w.meta.Annotations, annotation.WakeHolders)
+		delete(w.meta.Annotations, annotation.WakeOriginalReplicas)
+		delete(w.meta.Annotations, annotation.WakeWokenReplicas)
+
+		if changed {
+			fmt.Fprintf(c.streams.ErrOut, "Leaving %s/%s at %d replica(s), as they were changed since it was woken up\n", w.kind, w.meta.Name, w.replicas)
+		} else {
+			w.setReplicas(int32(original))
+
+			fmt.Fprintf(c.streams.ErrOut, "Scaling %s/%s back to %d replica(s)\n", w.kind, w.meta.Name, original)
+		}
+
+		return w.update(ctx)
+	})
+
+	return released, err
+}
+
+// WakeGC releases the woken up Deployments and StatefulSets of the namespace, all namespaces when empty, whose holds
+// have all expired, e.g., because the sessions holding them were killed, restoring their original replicas like the
+// last session releasing them would have. It returns the released workloads, e.g., "deployment/db".
+func (c Client) WakeGC(ctx context.Context, namespace string) ([]string, error) {
+	type listed struct {
+		kind string
+		obj  metav1.Object
+	}
+
+	objs := []listed{}
+
+	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing deployments: %w", err)
+	}
+
+	for i := range deployments.Items {
+		objs = append(objs, listed{kind: "deployment", obj: &deployments.Items[i]})
+	}
+
+	statefulSets, err := c.clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing statefulsets: %w", err)
+	}
+
+	for i := range statefulSets.Items {
+		objs = append(objs, listed{kind: "statefulset", obj: &statefulSets.Items[i]})
+	}
+
+	released := []string{}
+
+	for _, l := range objs {
+		name := fmt.Sprintf("%s/%s", l.kind, l.obj.GetName())
+		meta := &metav1.ObjectMeta{Annotations: l.obj.GetAnnotations()}
+
+		if _, woken := meta.Annotations[annotation.WakeOriginalReplicas]; !woken {
+			continue
+		}
+
+		holders, err := liveWakeHolders(meta, time.Now())
+		if err != nil {
+			return released, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if len(holders) > 0 {
+			continue
+		}
+
+		get, _ := c.workloadGetter(l.obj)
+
+		// No holder is removed, so the workload is only released should no session have started holding it since.
+		ok, err := c.releaseWake(ctx, get, WakeHolder{})
+		if err != nil {
+			return released, fmt.Errorf("releasing %s: %w", name, err)
+		}
+
+		if ok {
+			released = append(released, name)
+		}
+	}
+
+	return released, nil
+}
+
+// workloadGetter returns a function getting the current state of the workload obj refers to, or false when obj cannot
+// be woken up.
+func (c Client) workloadGetter(obj interface{}) (func(context.Context) (*workload, error), bool) {
+	switch t := obj.(type) {
+	case *appsv1.Deployment:
+		deployments := c.clientset.AppsV1().Deployments(t.Namespace)
+
+		return func(ctx context.Context) (*workload, error) {
+			d, err := deployments.Get(ctx, t.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+
+			return &workload{
+				kind:        "deployment",
+				meta:        &d.ObjectMeta,
+				replicas:    replicasOrDefault(d.Spec.Replicas),
+				ready:       d.Status.ReadyReplicas,
+				setReplicas: func(r int32) { d.Spec.Replicas = &r },
+				update: func(ctx context.Context) error {
+					_, err := deployments.Update(ctx, d, metav1.UpdateOptions{})
+
+					return err
+				},
+			}, nil
+		}, true
+	case *appsv1.StatefulSet:
+		statefulSets := c.clientset.AppsV1().StatefulSets(t.Namespace)
+
+		return func(ctx context.Context) (*workload, error) {
+			s, err := statefulSets.Get(ctx, t.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+
+			return &workload{
+				kind:        "statefulset",
+				meta:        &s.ObjectMeta,
+				replicas:    replicasOrDefault(s.Spec.Replicas),
+				ready:       s.Status.ReadyReplicas,
+				setReplicas: func(r int32) { s.Spec.Replicas = &r },
+				update: func(ctx context.Context) error {
+					_, err := statefulSets.Update(ctx, s, metav1.UpdateOptions{})
+
+					return err
+				},
+			}, nil
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// replicasOrDefault returns the desired replicas of a workload spec, which default to 1.
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+
+	return *replicas
+}
+
+// wakeReplicas returns the number of replicas a workload is scaled to when woken up.
+func wakeReplicas(meta *metav1.ObjectMeta) (int32, error) {
+	raw, ok := meta.Annotations[annotation.WakeReplicas]
+	if !ok {
+		return 1, nil
+	}
+
+	replicas, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil || replicas < 1 {
+		return 0, fmt.Errorf("invalid %s annotation %q, must be a positive number", annotation.WakeReplicas, raw)
+	}
+
+	return int32(replicas), nil
+}
+
+// wakeHolders returns the sessions holding a workload.
+func wakeHolders(meta *metav1.ObjectMeta) ([]WakeHolder, error) {
+	holders := []WakeHolder{}
+
+	raw, ok := meta.Annotations[annotation.WakeHolders]
+	if !ok {
+		return holders, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &holders); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", annotation.WakeHolders, err)
+	}
+
+	return holders, nil
+}
+
+// liveWakeHolders returns the sessions holding a workload whose holds have not expired as of now.
+func liveWakeHolders(meta *metav1.ObjectMeta, now time.Time) ([]WakeHolder, error) {
+	holders, err := wakeHolders(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	live := []WakeHolder{}
+
+	for _, h := range holders {
+		if !h.expired(now) {
+			live = append(live, h)
+		}
+	}
+
+	return live, nil
+}
+
+// setWakeHolders stores the sessions holding a workload.
+func setWakeHolders(meta *metav1.ObjectMeta, holders []WakeHolder) error {
+	b, err := json.Marshal(holders)
+	if err != nil {
+		return err
+	}
+
+	metav1.SetMetaDataAnnotation(meta, annotation.WakeHolders, string(b))
+
+	return nil
+}
+
+// newWakeHolderID returns an identifier of the current session, naming its host and process for troubleshooting.
+func newWakeHolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), utilrand.String(5))
+}
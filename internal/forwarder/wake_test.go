@@ -0,0 +1,355 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
+)
+
+func TestClientWake(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC().Truncate(time.Second).Format(time.RFC3339)
+	otherHolder := fmt.Sprintf(`[{"id":"other","since":%q,"renewed":%q}]`, now, now)
+	expiredHolder := `[{"id":"other","since":"2022-10-01T12:00:00Z","renewed":"2022-10-01T12:00:00Z"}]`
+
+	cases := []struct {
+		name string
+
+		object      runtime.Object
+		annotations map[string]string
+		replicas    int32
+		unready     bool
+		// scaledTo is the number of replicas the workload is scaled to by someone else while awake.
+		scaledTo *int32
+
+		awake       int32
+		holders     int
+		released    int32
+		releasedAnn map[string]string
+		error       bool
+	}{
+		{
+			name:     "wake up a deployment without replicas",
+			object:   &appsv1.Deployment{},
+			replicas: 0,
+			awake:    1,
+			holders:  1,
+			released: 0,
+		},
+		{
+			name:        "wake up to the annotated replicas",
+			object:      &appsv1.StatefulSet{},
+			annotations: map[string]string{annotation.WakeReplicas: "3"},
+			replicas:    0,
+			awake:       3,
+			holders:     1,
+			released:    0,
+			releasedAnn: map[string]string{annotation.WakeReplicas: "3"},
+		},
+		{
+			name:     "leave running workloads unchanged",
+			object:   &appsv1.Deployment{},
+			replicas: 2,
+			awake:    2,
+			released: 2,
+		},
+		{
+			name:   "join a workload woken up by another session",
+			object: &appsv1.Deployment{},
+			annotations: map[string]string{
+				annotation.WakeHolders:          otherHolder,
+				annotation.WakeOriginalReplicas: "0",
+			},
+			replicas: 1,
+			awake:    1,
+			holders:  2,
+			released: 1,
+			releasedAnn: map[string]string{
+				annotation.WakeHolders:          otherHolder,
+				annotation.WakeOriginalReplicas: "0",
+			},
+		},
+		{
+			name:   "take over a workload whose holders expired",
+			object: &appsv1.Deployment{},
+			annotations: map[string]string{
+				annotation.WakeHolders:          expiredHolder,
+				annotation.WakeOriginalReplicas: "0",
+			},
+			replicas: 1,
+			awake:    1,
+			holders:  1,
+			released: 0,
+		},
+		{
+			name:     "leave replicas changed while awake",
+			object:   &appsv1.Deployment{},
+			replicas: 0,
+			scaledTo: pointer.Int32(3),
+			awake:    1,
+			holders:  1,
+			released: 3,
+		},
+		{
+			name:   "leave replicas changed before taking over",
+			object: &appsv1.StatefulSet{},
+			annotations: map[string]string{
+				annotation.WakeHolders:          expiredHolder,
+				annotation.WakeOriginalReplicas: "0",
+				annotation.WakeWokenReplicas:    "1",
+			},
+			replicas: 2,
+			awake:    2,
+			holders:  1,
+			released: 2,
+		},
+		{
+			name:     "restore replicas when no replica becomes ready",
+			object:   &appsv1.Deployment{},
+			replicas: 0,
+			unready:  true,
+			error:    true,
+			released: 0,
+		},
+		{
+			name:        "fail on an invalid replicas annotation",
+			object:      &appsv1.Deployment{},
+			annotations: map[string]string{annotation.WakeReplicas: "none"},
+			replicas:    0,
+			error:       true,
+			released:    0,
+			releasedAnn: map[string]string{annotation.WakeReplicas: "none"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			meta := metav1.ObjectMeta{Name: "db", Namespace: "db", Annotations: tc.annotations}
+
+			var obj runtime.Object
+
+			switch tc.object.(type) {
+			case *appsv1.Deployment:
+				obj = &appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{Replicas: pointer.Int32(tc.replicas)}}
+			case *appsv1.StatefulSet:
+				obj = &appsv1.StatefulSet{ObjectMeta: meta, Spec: appsv1.StatefulSetSpec{Replicas: pointer.Int32(tc.replicas)}}
+			}
+
+			clientset := fake.NewSimpleClientset(obj)
+
+			// Replicas become ready as soon as they are requested, as no controller runs in tests.
+			clientset.PrependReactor("update", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if tc.unready {
+					return false, nil, nil
+				}
+
+				switch o := action.(k8stesting.UpdateAction).GetObject().(type) {
+				case *appsv1.Deployment:
+					o.Status.ReadyReplicas = *o.Spec.Replicas
+				case *appsv1.StatefulSet:
+					o.Status.ReadyReplicas = *o.Spec.Replicas
+				}
+
+				return false, nil, nil
+			})
+
+			client := NewClient(0, genericclioptions.NewTestIOStreamsDiscard())
+			client.clientset = clientset
+			client.Namespace = "db"
+			client.ObjectFn = func(resource string, namespace string) (runtime.Object, error) {
+				return obj, nil
+			}
+
+			release, err := client.Wake(context.Background(), "db", 2*time.Second)
+
+			if tc.error {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+
+				replicas, annotations := workloadState(t, clientset, obj)
+				assert.Equal(t, tc.awake, replicas)
+
+				holders, err := wakeHolders(&metav1.ObjectMeta{Annotations: annotations})
+				require.NoError(t, err)
+				assert.Len(t, holders, tc.holders)
+
+				if tc.scaledTo != nil {
+					scaleWorkload(t, clientset, obj, *tc.scaledTo)
+				}
+
+				require.NoError(t, release(context.Background()))
+			}
+
+			replicas, annotations := workloadState(t, clientset, obj)
+			assert.Equal(t, tc.released, replicas)
+
+			if tc.releasedAnn == nil {
+				assert.Empty(t, annotations)
+			} else {
+				assert.Equal(t, tc.releasedAnn, annotations)
+			}
+		})
+	}
+}
+
+func TestClientWakePod(t *testing.T) {
+	t.Parallel()
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "db"}}
+
+	client := NewClient(0, genericclioptions.NewTestIOStreamsDiscard())
+	client.clientset = fake.NewSimpleClientset(pod)
+	client.ObjectFn = func(resource string, namespace string) (runtime.Object, error) {
+		return pod, nil
+	}
+
+	release, err := client.Wake(context.Background(), "pod/db-0", time.Second)
+	require.NoError(t, err)
+	assert.NoError(t, release(context.Background()))
+}
+
+func TestClientRenewWake(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	now := since.Add(WakeHolderTTL + time.Minute)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "db", Annotations: map[string]string{
+			annotation.WakeHolders:          `[{"id":"other","since":"2022-10-01T12:00:00Z","renewed":"2022-10-01T12:00:00Z"}]`,
+			annotation.WakeOriginalReplicas: "0",
+		}},
+		Spec: appsv1.DeploymentSpec{Replicas: pointer.Int32(1)},
+	}
+
+	clientset := fake.NewSimpleClientset(deployment)
+
+	client := NewClient(0, genericclioptions.NewTestIOStreamsDiscard())
+	client.clientset = clientset
+
+	get, ok := client.workloadGetter(deployment)
+	require.True(t, ok)
+
+	// The session's own hold expired too, e.g., while its connection was lost, and is added back.
+	require.NoError(t, client.renewWake(context.Background(), get, WakeHolder{ID: "session", Since: since, Renewed: since}, now))
+
+	_, annotations := workloadState(t, clientset, deployment)
+
+	holders, err := wakeHolders(&metav1.ObjectMeta{Annotations: annotations})
+	require.NoError(t, err)
+	assert.Equal(t, []WakeHolder{{ID: "session", Since: since, Renewed: now}}, holders)
+}
+
+func TestClientWakeGC(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC().Truncate(time.Second).Format(time.RFC3339)
+	liveHolder := fmt.Sprintf(`[{"id":"other","since":%q,"renewed":%q}]`, now, now)
+	expiredHolder := `[{"id":"other","since":"2022-10-01T12:00:00Z","renewed":"2022-10-01T12:00:00Z"}]`
+
+	woken := func(holders string) map[string]string {
+		return map[string]string{
+			annotation.WakeHolders:          holders,
+			annotation.WakeOriginalReplicas: "0",
+			annotation.WakeWokenReplicas:    "1",
+		}
+	}
+
+	expired := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "expired", Namespace: "db", Annotations: woken(expiredHolder)},
+		Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32(1)},
+	}
+	held := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "held", Namespace: "db", Annotations: woken(liveHolder)},
+		Spec:       appsv1.StatefulSetSpec{Replicas: pointer.Int32(1)},
+	}
+	running := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "db"},
+		Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32(2)},
+	}
+
+	clientset := fake.NewSimpleClientset(expired, held, running)
+
+	client := NewClient(0, genericclioptions.NewTestIOStreamsDiscard())
+	client.clientset = clientset
+
+	released, err := client.WakeGC(context.Background(), "db")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deployment/expired"}, released)
+
+	replicas, annotations := workloadState(t, clientset, expired)
+	assert.Equal(t, int32(0), replicas)
+	assert.Empty(t, annotations)
+
+	replicas, annotations = workloadState(t, clientset, held)
+	assert.Equal(t, int32(1), replicas)
+	assert.Equal(t, woken(liveHolder), annotations)
+
+	replicas, _ = workloadState(t, clientset, running)
+	assert.Equal(t, int32(2), replicas)
+}
+
+// scaleWorkload sets the replicas of the workload, like someone scaling it with kubectl scale would.
+func scaleWorkload(t *testing.T, clientset *fake.Clientset, obj runtime.Object, replicas int32) {
+	t.Helper()
+
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		d, err := clientset.AppsV1().Deployments(o.Namespace).Get(context.Background(), o.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		d.Spec.Replicas = &replicas
+		_, err = clientset.AppsV1().Deployments(o.Namespace).Update(context.Background(), d, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	case *appsv1.StatefulSet:
+		s, err := clientset.AppsV1().StatefulSets(o.Namespace).Get(context.Background(), o.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		s.Spec.Replicas = &replicas
+		_, err = clientset.AppsV1().StatefulSets(o.Namespace).Update(context.Background(), s, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	default:
+		t.Fatalf("unexpected workload %T", obj)
+	}
+}
+
+// workloadState returns the current replicas and annotations of the workload.
+func workloadState(t *testing.T, clientset *fake.Clientset, obj runtime.Object) (int32, map[string]string) {
+	t.Helper()
+
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		d, err := clientset.AppsV1().Deployments(o.Namespace).Get(context.Background(), o.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		return *d.Spec.Replicas, d.Annotations
+	case *appsv1.StatefulSet:
+		s, err := clientset.AppsV1().StatefulSets(o.Namespace).Get(context.Background(), o.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		return *s.Spec.Replicas, s.Annotations
+	}
+
+	t.Fatalf("unexpected workload %T", obj)
+
+	return 0, nil
+}
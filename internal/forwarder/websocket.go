@@ -0,0 +1,400 @@
+package forwarder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/rest"
+)
+
+// WebSocketProtocol is the subprotocol of the kubelet's WebSocket port-forwarding endpoint. Each message is prefixed
+// with its channel: a forwarded port uses a data channel followed by an error channel, and the first two bytes read
+// from each channel are the port number.
+//
+// There is no v2 port-forwarding WebSocket subprotocol: v4 is the version of the kubelet's channel protocol, which API
+// servers proxy as is to the kubelet. The newer SPDY/3.1+portforward.k8s.io protocol tunnels SPDY over a WebSocket
+// but is only served by API servers 1.30 and up with the PortForwardWebsockets feature enabled, so the channel
+// protocol is used to support older clusters.
+const WebSocketProtocol = "v4.channel.k8s.io"
+
+// Channels of a WebSocket port-forwarding connection forwarding a single port.
+const (
+	dataChannel = iota
+	errorChannel
+)
+
+// UpgradeError is returned when the API server rejects a WebSocket upgrade, e.g., because a proxy does not support
+// WebSockets.
+type UpgradeError struct {
+	Err error
+}
+
+// Error returns the reason the upgrade failed.
+func (e *UpgradeError) Error() string {
+	return fmt.Sprintf("upgrading to websocket: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *UpgradeError) Unwrap() error {
+	return e.Err
+}
+
+// websocketDialer is an httpstream.Dialer forwarding ports over WebSocket connections. The protocol forwards a single
+// connection per WebSocket, so a WebSocket is opened for each forwarded connection.
+type websocketDialer struct {
+	config *rest.Config
+	url    *url.URL
+	port   string
+}
+
+// newWebSocketDialer returns a dialer opening WebSockets to the portforward URL of a pod, forwarding the passed remote
+// port.
+func newWebSocketDialer(config *rest.Config, u *url.URL, port string) *websocketDialer {
+	return &websocketDialer{config: config, url: u, port: port}
+}
+
+// Dial verifies that the API server accepts WebSocket connections by opening one. It is closed right away, since the
+// kubelet connects it to the pod's port, where servers with authentication or idle timeouts would close it before the
+// first connection is forwarded over it.
+func (d *websocketDialer) Dial(_ ...string) (httpstream.Connection, string, error) {
+	ws, err := d.dial(d.port)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ws.Close()
+
+	conn := &websocketConnection{
+		dial:      d.dial,
+		pairs:     map[string]*websocketPair{},
+		closeChan: make(chan bool),
+	}
+
+	return conn, WebSocketProtocol, nil
+}
+
+// dial opens a WebSocket forwarding the passed remote port.
+func (d *websocketDialer) dial(port string) (*websocket.Conn, error) {
+	u := *d.url
+	q := u.Query()
+	q.Set("ports", port)
+	u.RawQuery = q.Encode()
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+
+	tlsConfig, err := rest.TLSConfigFor(d.config)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := d.config.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	rt := &websocketRoundTripper{dialer: &websocket.Dialer{
+		Proxy:            proxy,
+		TLSClientConfig:  tlsConfig,
+		Subprotocols:     []string{WebSocketProtocol},
+		HandshakeTimeout: 30 * time.Second,
+	}}
+
+	// The wrappers add the rest configuration's authentication and user agent headers to the upgrade request.
+	wrapped, err := rest.HTTPWrappersForConfig(d.config, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := wrapped.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	if rt.conn.Subprotocol() != WebSocketProtocol {
+		rt.conn.Close()
+
+		return nil, &UpgradeError{Err: fmt.Errorf("unsupported subprotocol %q", rt.conn.Subprotocol())}
+	}
+
+	return rt.conn, nil
+}
+
+// websocketRoundTripper is an http.RoundTripper upgrading requests to WebSocket connections.
+type websocketRoundTripper struct {
+	dialer *websocket.Dialer
+	conn   *websocket.Conn
+}
+
+// RoundTrip opens a WebSocket with the request's URL and headers.
+func (rt *websocketRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := req.Header.Clone()
+
+	// The WebSocket handshake headers are set by the dialer, which refuses duplicates.
+	for _, h := range []string{"Connection", "Upgrade", "Sec-Websocket-Protocol", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions"} {
+		header.Del(h)
+	}
+
+	conn, resp, err := rt.dialer.DialContext(req.Context(), req.URL.String(), header)
+	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			if resp != nil {
+				err = fmt.Errorf("%w: %s", err, resp.Status)
+			}
+
+			return nil, &UpgradeError{Err: err}
+		}
+
+		return nil, err
+	}
+
+	rt.conn = conn
+
+	return resp, nil
+}
+
+// websocketConnection is an httpstream.Connection opening a WebSocket for each pair of error and data streams created
+// by the port forwarder for a forwarded connection.
+type websocketConnection struct {
+	dial func(port string) (*websocket.Conn, error)
+
+	mu        sync.Mutex
+	pairs     map[string]*websocketPair
+	closed    bool
+	closeChan chan bool
+}
+
+// CreateStream returns a stream of the WebSocket forwarding the connection identified by the request ID header. The
+// error stream is created first and opens the WebSocket.
+func (c *websocketConnection) CreateStream(headers http.Header) (httpstream.Stream, error) {
+	requestID := headers.Get(corev1.PortForwardRequestIDHeader)
+
+	c.mu.Lock()
+	pair, ok := c.pairs[requestID]
+	c.mu.Unlock()
+
+	switch headers.Get(corev1.StreamType) {
+	case corev1.StreamTypeError:
+		if ok {
+			return nil, fmt.Errorf("error stream already created for request %s", requestID)
+		}
+
+		ws, err := c.dial(headers.Get(corev1.PortHeader))
+		if err != nil {
+			return nil, err
+		}
+
+		pair = newWebSocketPair(ws, headers)
+
+		c.mu.Lock()
+		c.pairs[requestID] = pair
+		c.mu.Unlock()
+
+		go func() {
+			pair.read()
+
+			c.mu.Lock()
+			delete(c.pairs, requestID)
+			c.mu.Unlock()
+		}()
+
+		return pair.streams[errorChannel], nil
+	case corev1.StreamTypeData:
+		if !ok {
+			return nil, fmt.Errorf("no error stream created for request %s", requestID)
+		}
+
+		return pair.streams[dataChannel], nil
+	default:
+		return nil, fmt.Errorf("unsupported stream type %q", headers.Get(corev1.StreamType))
+	}
+}
+
+// Close closes every WebSocket.
+func (c *websocketConnection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, pair := range c.pairs {
+		pair.close()
+	}
+
+	if !c.closed {
+		c.closed = true
+		close(c.closeChan)
+	}
+
+	return nil
+}
+
+// CloseChan returns a channel closed once the connection is closed.
+func (c *websocketConnection) CloseChan() <-chan bool {
+	return c.closeChan
+}
+
+// SetIdleTimeout is a no-op, WebSockets are closed along with the forwarded connection.
+func (c *websocketConnection) SetIdleTimeout(time.Duration) {}
+
+// RemoveStreams is a no-op, streams are removed once their WebSocket is closed.
+func (c *websocketConnection) RemoveStreams(...httpstream.Stream) {}
+
+// websocketPair is a WebSocket carrying the data and error streams of a single forwarded connection.
+type websocketPair struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	once    sync.Once
+	streams [2]*websocketStream
+}
+
+// newWebSocketPair returns the streams of a WebSocket.
+func newWebSocketPair(ws *websocket.Conn, headers http.Header) *websocketPair {
+	p := &websocketPair{ws: ws}
+
+	for _, channel := range []byte{dataChannel, errorChannel} {
+		h := headers.Clone()
+		if channel == dataChannel {
+			h.Set(corev1.StreamType, corev1.StreamTypeData)
+		}
+
+		r, w := io.Pipe()
+		p.streams[channel] = &websocketStream{pair: p, channel: channel, headers: h, reader: r, writer: w}
+	}
+
+	return p
+}
+
+// read demultiplexes messages to the streams until the WebSocket is closed, which ends the streams.
+func (p *websocketPair) read() {
+	defer func() {
+		for _, s := range p.streams {
+			s.writer.Close()
+		}
+	}()
+
+	for {
+		_, msg, err := p.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if len(msg) == 0 || int(msg[0]) >= len(p.streams) {
+			continue
+		}
+
+		if err := p.streams[msg[0]].receive(msg[1:]); err != nil {
+			return
+		}
+	}
+}
+
+// write sends data on a channel.
+func (p *websocketPair) write(channel byte, data []byte) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	return p.ws.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, data...))
+}
+
+// close closes the WebSocket, ending both streams.
+func (p *websocketPair) close() {
+	p.once.Do(func() {
+		p.writeMu.Lock()
+		_ = p.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		p.writeMu.Unlock()
+
+		p.ws.Close()
+	})
+}
+
+// websocketStream is a channel of a WebSocket.
+type websocketStream struct {
+	pair    *websocketPair
+	channel byte
+	headers http.Header
+
+	reader *io.PipeReader
+	writer *io.PipeWriter
+	// prefix counts the bytes of the port number prefix received so far.
+	prefix int
+}
+
+// receive passes data received on the channel to the reader, skipping the port number prefix.
+func (s *websocketStream) receive(data []byte) error {
+	if s.prefix < 2 {
+		n := 2 - s.prefix
+		if n > len(data) {
+			n = len(data)
+		}
+
+		s.prefix += n
+		data = data[n:]
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := s.writer.Write(data)
+
+	return err
+}
+
+// Read reads data received on the channel.
+func (s *websocketStream) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
+
+// Write sends data on the channel.
+func (s *websocketStream) Write(p []byte) (int, error) {
+	if err := s.pair.write(s.channel, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the data stream's WebSocket, as the protocol cannot signal that no more data will be sent. Closing the
+// error stream is a no-op, it is never written to.
+func (s *websocketStream) Close() error {
+	if s.channel == dataChannel {
+		s.pair.close()
+	}
+
+	return nil
+}
+
+// Reset closes the WebSocket.
+func (s *websocketStream) Reset() error {
+	s.pair.close()
+
+	return nil
+}
+
+// Headers returns the headers the stream was created with.
+func (s *websocketStream) Headers() http.Header {
+	return s.headers
+}
+
+// Identifier returns the stream's channel.
+func (s *websocketStream) Identifier() uint32 {
+	return uint32(s.channel)
+}
@@ -0,0 +1,58 @@
+package kubetest
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// apiVersions is the discovery document of the core group.
+var apiVersions = &metav1.APIVersions{
+	TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+	Versions: []string{"v1"},
+}
+
+// apiGroups is the discovery document listing the named groups.
+var apiGroups = &metav1.APIGroupList{
+	TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+	Groups: []metav1.APIGroup{
+		newAPIGroup("apps", "v1"),
+		newAPIGroup("authorization.k8s.io", "v1"),
+	},
+}
+
+// readVerbs are the verbs allowed on the served resources.
+var readVerbs = metav1.Verbs{"get", "list", "watch"}
+
+// apiResources are the discovery documents of each served group version.
+var apiResources = map[string]*metav1.APIResourceList{
+	"v1": newAPIResourceList("v1",
+		metav1.APIResource{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod", Verbs: readVerbs, ShortNames: []string{"po"}},
+		metav1.APIResource{Name: "pods/portforward", Namespaced: true, Kind: "PodPortForwardOptions", Verbs: metav1.Verbs{"create", "get"}},
+		metav1.APIResource{Name: "services", SingularName: "service", Namespaced: true, Kind: "Service", Verbs: readVerbs, ShortNames: []string{"svc"}},
+	),
+	"apps/v1": newAPIResourceList("apps/v1",
+		metav1.APIResource{Name: "deployments", SingularName: "deployment", Namespaced: true, Kind: "Deployment", Verbs: readVerbs, ShortNames: []string{"deploy"}},
+	),
+	"authorization.k8s.io/v1": newAPIResourceList("authorization.k8s.io/v1",
+		metav1.APIResource{Name: "selfsubjectaccessreviews", SingularName: "selfsubjectaccessreview", Kind: "SelfSubjectAccessReview", Verbs: metav1.Verbs{"create"}},
+	),
+}
+
+// newAPIGroup returns the discovery document of a group served at a single version.
+func newAPIGroup(name string, version string) metav1.APIGroup {
+	gv := metav1.GroupVersionForDiscovery{GroupVersion: name + "/" + version, Version: version}
+
+	return metav1.APIGroup{
+		Name:             name,
+		Versions:         []metav1.GroupVersionForDiscovery{gv},
+		PreferredVersion: gv,
+	}
+}
+
+// newAPIResourceList returns the discovery document of a group version's resources.
+func newAPIResourceList(groupVersion string, resources ...metav1.APIResource) *metav1.APIResourceList {
+	return &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: groupVersion,
+		APIResources: resources,
+	}
+}
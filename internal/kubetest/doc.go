@@ -0,0 +1,5 @@
+// Package kubetest implements an in-process fake Kubernetes API server for tests. It serves the discovery documents,
+// pods, services and deployments needed to resolve forwarding targets, answers access reviews and accepts
+// SPDY and WebSocket port-forwarding connections, relaying them to a local TCP echo server or a configured upstream, so that the forwarding lifecycle can be
+// exercised end-to-end without a cluster.
+package kubetest
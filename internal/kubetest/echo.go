@@ -0,0 +1,51 @@
+package kubetest
+
+import (
+	"io"
+	"net"
+)
+
+// echoServer is a TCP server writing back everything it reads, standing in for the forwarded container port.
+type echoServer struct {
+	listener net.Listener
+}
+
+// newEchoServer starts an echo server on a free local port.
+func newEchoServer() (*echoServer, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	e := &echoServer{listener: l}
+
+	go e.serve()
+
+	return e, nil
+}
+
+// Addr returns the address the server listens on.
+func (e *echoServer) Addr() string {
+	return e.listener.Addr().String()
+}
+
+// Close stops accepting connections.
+func (e *echoServer) Close() error {
+	return e.listener.Close()
+}
+
+// serve echoes each accepted connection until the listener is closed.
+func (e *echoServer) serve() {
+	for {
+		conn, err := e.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			_, _ = io.Copy(conn, conn)
+		}()
+	}
+}
@@ -0,0 +1,89 @@
+package kubetest
+
+import (
+	"io"
+	"net"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+)
+
+// portForwardProtocol is the SPDY port-forwarding subprotocol spoken by kubelets.
+const portForwardProtocol = "portforward.k8s.io"
+
+// newStream is a stream opened by the client, usable once the reply has been sent.
+type newStream struct {
+	stream    httpstream.Stream
+	replySent <-chan struct{}
+}
+
+// portForward upgrades the request to a SPDY connection and relays its data streams to the upstream server, whatever the
+// requested port.
+func (s *Server) portForward(w http.ResponseWriter, r *http.Request) {
+	if _, err := httpstream.Handshake(r, w, []string{portForwardProtocol}); err != nil {
+		return
+	}
+
+	streams := make(chan newStream)
+
+	conn := spdy.NewResponseUpgrader().UpgradeResponse(w, r, func(stream httpstream.Stream, replySent <-chan struct{}) error {
+		streams <- newStream{stream: stream, replySent: replySent}
+
+		return nil
+	})
+	if conn == nil {
+		return
+	}
+
+	defer conn.Close()
+
+	for {
+		select {
+		case ns := <-streams:
+			go s.handleStream(ns)
+		case <-conn.CloseChan():
+			return
+		}
+	}
+}
+
+// handleStream serves a single port-forwarding stream. Error streams are closed right away, reporting no error, and
+// data streams are relayed to the upstream server.
+func (s *Server) handleStream(ns newStream) {
+	<-ns.replySent
+
+	stream := ns.stream
+
+	if stream.Headers().Get(corev1.StreamType) != corev1.StreamTypeData {
+		stream.Close()
+
+		return
+	}
+
+	s.mu.Lock()
+	s.forwarded = append(s.forwarded, stream.Headers().Get(corev1.PortHeader))
+	s.mu.Unlock()
+
+	s.relay(stream)
+}
+
+// relay copies a stream to a new connection to the upstream server and back, until both sides are done.
+func (s *Server) relay(stream io.ReadWriteCloser) {
+	defer stream.Close()
+
+	conn, err := net.Dial("tcp", s.upstream())
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	go func() {
+		_, _ = io.Copy(conn, stream)
+		_ = conn.(*net.TCPConn).CloseWrite()
+	}()
+
+	_, _ = io.Copy(stream, conn)
+}
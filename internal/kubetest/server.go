@@ -0,0 +1,325 @@
+package kubetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// objectKey identifies a stored object.
+type objectKey struct {
+	resource  string
+	namespace string
+	name      string
+}
+
+// Server is a fake Kubernetes API server.
+type Server struct {
+	// Authorize decides access reviews. When nil, every access is allowed.
+	Authorize func(attributes authorizationv1.ResourceAttributes) bool
+	// Upstream is the address forwarded connections are relayed to, e.g., a SOCKS5 server standing in for a relay pod.
+	// When empty, connections are relayed to an echo server.
+	Upstream string
+	// RejectWebSocket rejects WebSocket port-forwarding requests, like proxies without WebSocket support.
+	RejectWebSocket bool
+
+	server *httptest.Server
+	echo   *echoServer
+
+	mu        sync.Mutex
+	objects   map[objectKey]runtime.Object
+	forwarded []string
+}
+
+// NewServer starts a server, which is stopped when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	echo, err := newEchoServer()
+	if err != nil {
+		t.Fatalf("starting echo server: %v", err)
+	}
+
+	s := &Server{
+		echo:    echo,
+		objects: map[objectKey]runtime.Object{},
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	t.Cleanup(func() {
+		s.server.Close()
+		echo.Close()
+	})
+
+	return s
+}
+
+// Add stores pods, services and deployments served by the server.
+func (s *Server) Add(objects ...runtime.Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, obj := range objects {
+		var key objectKey
+
+		switch o := obj.DeepCopyObject().(type) {
+		case *corev1.Pod:
+			o.TypeMeta = metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"}
+			key, obj = objectKey{"pods", o.Namespace, o.Name}, o
+		case *corev1.Service:
+			o.TypeMeta = metav1.TypeMeta{Kind: "Service", APIVersion: "v1"}
+			key, obj = objectKey{"services", o.Namespace, o.Name}, o
+		case *appsv1.Deployment:
+			o.TypeMeta = metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"}
+			key, obj = objectKey{"deployments", o.Namespace, o.Name}, o
+		default:
+			panic(fmt.Sprintf("kubetest: unsupported object %T", obj))
+		}
+
+		s.objects[key] = obj
+	}
+}
+
+// RESTConfig returns a rest configuration connecting to the server.
+func (s *Server) RESTConfig() *rest.Config {
+	return &rest.Config{Host: s.server.URL}
+}
+
+// WriteKubeconfig writes a kubeconfig file connecting to the server in the passed namespace, and returns its path.
+func (s *Server) WriteKubeconfig(t testing.TB, namespace string) string {
+	t.Helper()
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters["kubetest"] = &clientcmdapi.Cluster{Server: s.server.URL}
+	config.AuthInfos["kubetest"] = &clientcmdapi.AuthInfo{}
+	config.Contexts["kubetest"] = &clientcmdapi.Context{Cluster: "kubetest", AuthInfo: "kubetest", Namespace: namespace}
+	config.CurrentContext = "kubetest"
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		t.Fatalf("writing kubeconfig: %v", err)
+	}
+
+	return path
+}
+
+// Forwarded returns the remote ports of the port-forwarding connections handled so far, in order.
+func (s *Server) Forwarded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.forwarded...)
+}
+
+// upstream returns the address forwarded connections are relayed to.
+func (s *Server) upstream() string {
+	if s.Upstream != "" {
+		return s.Upstream
+	}
+
+	return s.echo.Addr()
+}
+
+// serveHTTP routes API requests.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+
+	switch path {
+	case "api":
+		writeJSON(w, http.StatusOK, apiVersions)
+
+		return
+	case "apis":
+		writeJSON(w, http.StatusOK, apiGroups)
+
+		return
+	}
+
+	var gv string
+
+	switch {
+	case strings.HasPrefix(path, "api/"):
+		path = strings.TrimPrefix(path, "api/")
+	case strings.HasPrefix(path, "apis/"):
+		path = strings.TrimPrefix(path, "apis/")
+	default:
+		writeNotFound(w, schema.GroupResource{}, path)
+
+		return
+	}
+
+	parts := strings.Split(path, "/")
+
+	// The group version is either "v1" or "<group>/<version>".
+	if parts[0] == "v1" {
+		gv, parts = parts[0], parts[1:]
+	} else if len(parts) >= 2 {
+		gv, parts = parts[0]+"/"+parts[1], parts[2:]
+	}
+
+	if len(parts) == 0 {
+		resources, ok := apiResources[gv]
+		if !ok {
+			writeNotFound(w, schema.GroupResource{}, gv)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, resources)
+
+		return
+	}
+
+	namespace := ""
+	if parts[0] == "namespaces" && len(parts) >= 3 {
+		namespace, parts = parts[1], parts[2:]
+	}
+
+	s.serveResource(w, r, namespace, parts)
+}
+
+// serveResource serves requests to resources, where parts is the path following the namespace: the resource, and
+// optionally the object's name and subresource.
+func (s *Server) serveResource(w http.ResponseWriter, r *http.Request, namespace string, parts []string) {
+	resource := parts[0]
+
+	switch {
+	case resource == "selfsubjectaccessreviews" && r.Method == http.MethodPost:
+		s.review(w, r)
+	case resource == "pods" && len(parts) == 1 && r.Method == http.MethodGet:
+		s.listPods(w, r, namespace)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		obj, ok := s.get(resource, namespace, parts[1])
+		if !ok {
+			writeNotFound(w, schema.GroupResource{Resource: resource}, parts[1])
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, obj)
+	case resource == "pods" && len(parts) == 3 && parts[2] == "portforward":
+		if _, ok := s.get(resource, namespace, parts[1]); !ok {
+			writeNotFound(w, schema.GroupResource{Resource: resource}, parts[1])
+
+			return
+		}
+
+		if !websocket.IsWebSocketUpgrade(r) {
+			s.portForward(w, r)
+
+			return
+		}
+
+		if s.RejectWebSocket {
+			http.Error(w, "websocket upgrades are not supported", http.StatusBadRequest)
+
+			return
+		}
+
+		s.portForwardWebSocket(w, r)
+	default:
+		writeNotFound(w, schema.GroupResource{Resource: resource}, strings.Join(parts[1:], "/"))
+	}
+}
+
+// get returns a stored object.
+func (s *Server) get(resource string, namespace string, name string) (runtime.Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[objectKey{resource, namespace, name}]
+
+	return obj, ok
+}
+
+// listPods serves the pods of a namespace matching the request's label selector.
+func (s *Server) listPods(w http.ResponseWriter, r *http.Request, namespace string) {
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+
+		return
+	}
+
+	list := &corev1.PodList{TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"}}
+
+	s.mu.Lock()
+
+	for key, obj := range s.objects {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || key.namespace != namespace || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		list.Items = append(list.Items, *pod)
+	}
+
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// review answers a SelfSubjectAccessReview.
+func (s *Server) review(w http.ResponseWriter, r *http.Request) {
+	review := &authorizationv1.SelfSubjectAccessReview{}
+
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+
+		return
+	}
+
+	review.TypeMeta = metav1.TypeMeta{Kind: "SelfSubjectAccessReview", APIVersion: "authorization.k8s.io/v1"}
+	review.Status.Allowed = true
+
+	if s.Authorize != nil && review.Spec.ResourceAttributes != nil {
+		review.Status.Allowed = s.Authorize(*review.Spec.ResourceAttributes)
+	}
+
+	if !review.Status.Allowed {
+		review.Status.Reason = "denied by kubetest"
+	}
+
+	writeJSON(w, http.StatusCreated, review)
+}
+
+// writeJSON writes obj as the JSON response body.
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(obj)
+}
+
+// writeStatus writes the status of an API error.
+func writeStatus(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.ErrStatus
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+
+	writeJSON(w, int(status.Code), status)
+}
+
+// writeNotFound writes a not found error for the passed resource.
+func writeNotFound(w http.ResponseWriter, resource schema.GroupResource, name string) {
+	writeStatus(w, apierrors.NewNotFound(resource, name))
+}
@@ -0,0 +1,98 @@
+package kubetest
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// webSocketProtocol is the channel subprotocol of the kubelet's WebSocket port-forwarding endpoint.
+const webSocketProtocol = "v4.channel.k8s.io"
+
+// portForwardWebSocket upgrades the request to a WebSocket relaying the data channel of the first requested port to
+// the upstream server. Every channel starts with the port number, as with kubelets.
+func (s *Server) portForwardWebSocket(w http.ResponseWriter, r *http.Request) {
+	port := strings.Split(r.URL.Query().Get("ports"), ",")[0]
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		http.Error(w, "invalid port", http.StatusBadRequest)
+
+		return
+	}
+
+	upgrader := websocket.Upgrader{Subprotocols: []string{webSocketProtocol}}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer ws.Close()
+
+	s.mu.Lock()
+	s.forwarded = append(s.forwarded, port)
+	s.mu.Unlock()
+
+	var writeMu sync.Mutex
+
+	write := func(channel byte, data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+
+		return ws.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, data...))
+	}
+
+	prefix := make([]byte, 2)
+	binary.LittleEndian.PutUint16(prefix, uint16(p))
+
+	for _, channel := range []byte{0, 1} {
+		if err := write(channel, prefix); err != nil {
+			return
+		}
+	}
+
+	conn, err := net.Dial("tcp", s.upstream())
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	go func() {
+		defer ws.Close()
+
+		buf := make([]byte, 32*1024)
+
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				if err := write(0, buf[:n]); err != nil {
+					return
+				}
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if len(msg) > 1 && msg[0] == 0 {
+			if _, err := conn.Write(msg[1:]); err != nil {
+				return
+			}
+		}
+	}
+}
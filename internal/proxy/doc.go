@@ -0,0 +1,4 @@
+// Package proxy serves local SOCKS5 and HTTP proxies whose connections are dialed through a relay, typically a SOCKS5
+// server running in the cluster that is reached over a port-forwarding connection. This lets a single forwarding
+// session reach any in-cluster service by its DNS name.
+package proxy
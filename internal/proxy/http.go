@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+)
+
+// hopHeaders are headers meant for the proxy itself, which are not sent on to the target.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// serveHTTP serves HTTP proxy requests: CONNECT requests are tunnelled to the requested address, while requests for
+// absolute URLs are sent on to their target.
+func (p *Proxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy, requests must use absolute URLs", http.StatusBadRequest)
+
+		return
+	}
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+
+		return
+	}
+
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+
+	w.WriteHeader(resp.StatusCode)
+
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// serveConnect tunnels the client connection to the address of a CONNECT request.
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunnelling is not supported", http.StatusInternalServerError)
+
+		return
+	}
+
+	target, err := p.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		target.Close()
+
+		return
+	}
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		conn.Close()
+		target.Close()
+
+		return
+	}
+
+	// Data sent by the client right after its request may already have been buffered.
+	if n := buf.Reader.Buffered(); n > 0 {
+		b, _ := buf.Reader.Peek(n)
+		if _, err := target.Write(b); err != nil {
+			conn.Close()
+			target.Close()
+
+			return
+		}
+	}
+
+	p.pipe(conn, target)
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Dialer opens connections to addresses on behalf of proxy clients.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Config configures the addresses the proxies listen on.
+type Config struct {
+	// SOCKSAddr is the address the SOCKS5 proxy listens on, e.g., "127.0.0.1:1080".
+	SOCKSAddr string
+	// HTTPAddr is the address the HTTP proxy, supporting CONNECT tunnels, listens on. When empty, no HTTP proxy is
+	// served.
+	HTTPAddr string
+}
+
+// Proxy is a set of local proxies dialing connections with the same dialer.
+type Proxy struct {
+	dial   Dialer
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	socks      net.Listener
+	httpServer *http.Server
+	httpAddr   string
+	transport  *http.Transport
+}
+
+// Listen starts the proxies configured by config, dialing connections with dial.
+func Listen(config Config, dial Dialer) (*Proxy, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &Proxy{
+		dial:      dial,
+		ctx:       ctx,
+		cancel:    cancel,
+		transport: &http.Transport{DialContext: dial},
+	}
+
+	socks, err := net.Listen("tcp", config.SOCKSAddr)
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	p.socks = socks
+
+	if config.HTTPAddr != "" {
+		l, err := net.Listen("tcp", config.HTTPAddr)
+		if err != nil {
+			socks.Close()
+			cancel()
+
+			return nil, err
+		}
+
+		p.httpAddr = l.Addr().String()
+		p.httpServer = &http.Server{
+			Handler:     http.HandlerFunc(p.serveHTTP),
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+
+		p.wg.Add(1)
+
+		go func() {
+			defer p.wg.Done()
+
+			_ = p.httpServer.Serve(l)
+		}()
+	}
+
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		p.acceptSOCKS()
+	}()
+
+	return p, nil
+}
+
+// SOCKSAddr returns the address the SOCKS5 proxy listens on.
+func (p *Proxy) SOCKSAddr() string {
+	return p.socks.Addr().String()
+}
+
+// HTTPAddr returns the address the HTTP proxy listens on, or an empty string when it is not served.
+func (p *Proxy) HTTPAddr() string {
+	return p.httpAddr
+}
+
+// Close stops the proxies and closes their open connections.
+func (p *Proxy) Close() error {
+	p.cancel()
+
+	err := p.socks.Close()
+
+	if p.httpServer != nil {
+		if httpErr := p.httpServer.Close(); err == nil {
+			err = httpErr
+		}
+	}
+
+	p.transport.CloseIdleConnections()
+	p.wg.Wait()
+
+	return err
+}
+
+// acceptSOCKS serves SOCKS5 clients until the listener is closed.
+func (p *Proxy) acceptSOCKS() {
+	for {
+		conn, err := p.socks.Accept()
+		if err != nil {
+			return
+		}
+
+		p.wg.Add(1)
+
+		go func() {
+			defer p.wg.Done()
+
+			p.serveSOCKS(conn)
+		}()
+	}
+}
+
+// pipe copies data between the client and target connections until either is closed or the proxy is closed, then
+// closes both.
+func (p *Proxy) pipe(client io.ReadWriteCloser, target io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+
+	copyConn := func(dst io.Writer, src io.Reader) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+
+	go copyConn(target, client)
+	go copyConn(client, target)
+
+	finished := 0
+
+	select {
+	case <-done:
+		finished++
+	case <-p.ctx.Done():
+	}
+
+	client.Close()
+	target.Close()
+
+	for ; finished < 2; finished++ {
+		<-done
+	}
+}
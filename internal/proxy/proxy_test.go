@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/proxy"
+)
+
+func TestProxy(t *testing.T) {
+	t.Parallel()
+
+	echo := newEcho(t)
+	_, echoPort, err := net.SplitHostPort(echo.Addr().String())
+	require.NoError(t, err)
+
+	cases := []struct {
+		name string
+
+		dial func(t *testing.T, p *Proxy, address string) (net.Conn, error)
+
+		target string
+		error  bool
+	}{
+		{
+			name:   "socks5",
+			dial:   dialSOCKS,
+			target: net.JoinHostPort("localhost", echoPort),
+		},
+		{
+			name:   "http connect",
+			dial:   dialConnect,
+			target: net.JoinHostPort("localhost", echoPort),
+		},
+		{
+			name:   "socks5 unreachable target",
+			dial:   dialSOCKS,
+			target: net.JoinHostPort("localhost", strconv.Itoa(closedPort(t))),
+			error:  true,
+		},
+		{
+			name:   "http connect unreachable target",
+			dial:   dialConnect,
+			target: net.JoinHostPort("localhost", strconv.Itoa(closedPort(t))),
+			error:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := newRelayedProxy(t)
+
+			conn, err := tc.dial(t, p, tc.target)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("hello"))
+			require.NoError(t, err)
+
+			b := make([]byte, len("hello"))
+			_, err = io.ReadFull(conn, b)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(b))
+		})
+	}
+}
+
+func TestProxyHTTP(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Proxy-Connection"))
+		fmt.Fprint(w, "hello")
+	}))
+	t.Cleanup(target.Close)
+
+	p := newRelayedProxy(t)
+
+	proxyURL, err := url.Parse("http://" + p.HTTPAddr())
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	req, err := http.NewRequest(http.MethodGet, target.URL, nil)
+	require.NoError(t, err)
+
+	req.Header.Set("Proxy-Connection", "keep-alive")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(b))
+}
+
+// newRelayedProxy returns a proxy dialing through a relay SOCKS5 server, like the one of a relay pod.
+func newRelayedProxy(t *testing.T) *Proxy {
+	t.Helper()
+
+	relay, err := Listen(Config{SOCKSAddr: "127.0.0.1:0"}, (&net.Dialer{}).DialContext)
+	require.NoError(t, err)
+	t.Cleanup(func() { relay.Close() })
+
+	p, err := Listen(Config{SOCKSAddr: "127.0.0.1:0", HTTPAddr: "127.0.0.1:0"}, RelayDialer(relay.SOCKSAddr()))
+	require.NoError(t, err)
+	t.Cleanup(func() { p.Close() })
+
+	return p
+}
+
+// dialSOCKS connects to address through the SOCKS5 proxy.
+func dialSOCKS(t *testing.T, p *Proxy, address string) (net.Conn, error) {
+	t.Helper()
+
+	dialer, err := proxy.SOCKS5("tcp", p.SOCKSAddr(), nil, proxy.Direct)
+	require.NoError(t, err)
+
+	return dialer.Dial("tcp", address)
+}
+
+// dialConnect connects to address through a CONNECT tunnel of the HTTP proxy.
+func dialConnect(t *testing.T, p *Proxy, address string) (net.Conn, error) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", p.HTTPAddr())
+	require.NoError(t, err)
+
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", address, address)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return conn, nil
+}
+
+// newEcho returns a listener echoing back data sent to it.
+func newEcho(t *testing.T) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return l
+}
+
+// closedPort returns a local port nothing listens on.
+func closedPort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	return port
+}
@@ -0,0 +1,21 @@
+package proxy
+
+import (
+	"context"
+	"net"
+
+	"golang.org/x/net/proxy"
+)
+
+// RelayDialer returns a dialer connecting through the SOCKS5 server listening at address, e.g., the local port of a
+// connection forwarded to a relay pod. Target names are resolved by the relay, so cluster DNS names can be dialed.
+func RelayDialer(address string) Dialer {
+	return func(ctx context.Context, network, target string) (net.Conn, error) {
+		dialer, err := proxy.SOCKS5("tcp", address, nil, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+
+		return dialer.(proxy.ContextDialer).DialContext(ctx, network, target)
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5 protocol values, see RFC 1928.
+const (
+	socksVersion = 5
+
+	socksNoAuth       = 0
+	socksNoAcceptable = 0xff
+
+	socksConnect = 1
+
+	socksIPv4   = 1
+	socksDomain = 3
+	socksIPv6   = 4
+
+	socksSucceeded          = 0
+	socksHostUnreachable    = 4
+	socksCommandUnsupported = 7
+	socksAddressUnsupported = 8
+)
+
+// serveSOCKS serves a SOCKS5 client. Only the CONNECT command without authentication is supported.
+func (p *Proxy) serveSOCKS(conn net.Conn) {
+	defer conn.Close()
+
+	if err := socksHandshake(conn); err != nil {
+		return
+	}
+
+	address, reply := readSOCKSRequest(conn)
+	if reply != socksSucceeded {
+		_ = writeSOCKSReply(conn, reply)
+
+		return
+	}
+
+	target, err := p.dial(p.ctx, "tcp", address)
+	if err != nil {
+		_ = writeSOCKSReply(conn, socksHostUnreachable)
+
+		return
+	}
+
+	if err := writeSOCKSReply(conn, socksSucceeded); err != nil {
+		target.Close()
+
+		return
+	}
+
+	p.pipe(conn, target)
+}
+
+// socksHandshake negotiates the authentication method, accepting only clients offering no authentication.
+func socksHandshake(conn io.ReadWriter) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+
+	if header[0] != socksVersion {
+		return fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	for _, m := range methods {
+		if m == socksNoAuth {
+			_, err := conn.Write([]byte{socksVersion, socksNoAuth})
+
+			return err
+		}
+	}
+
+	_, _ = conn.Write([]byte{socksVersion, socksNoAcceptable})
+
+	return fmt.Errorf("no supported SOCKS authentication method")
+}
+
+// readSOCKSRequest reads a client request, returning the address to connect to or the reply code refusing it.
+func readSOCKSRequest(conn io.Reader) (string, byte) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", socksHostUnreachable
+	}
+
+	var host string
+
+	switch header[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if header[3] == socksIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", socksHostUnreachable
+		}
+
+		host = ip.String()
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", socksHostUnreachable
+		}
+
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", socksHostUnreachable
+		}
+
+		host = string(domain)
+	default:
+		return "", socksAddressUnsupported
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", socksHostUnreachable
+	}
+
+	if header[1] != socksConnect {
+		return "", socksCommandUnsupported
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), socksSucceeded
+}
+
+// writeSOCKSReply writes a reply with the passed code. The bound address is not meaningful for relayed connections, so
+// the unspecified address is sent.
+func writeSOCKSReply(conn io.Writer, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+
+	return err
+}
@@ -0,0 +1,3 @@
+// Package relay manages short-lived relay pods, which forward connections from within the cluster to an external
+// endpoint, such as a managed database only reachable from the cluster's network.
+package relay
@@ -0,0 +1,194 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Label marks relay pods, so that orphaned ones can be found and garbage collected.
+	Label = "exec-forward.pod.kubernetes.io/relay"
+	// ToAnnotation records the address a relay pod forwards connections to.
+	ToAnnotation = "exec-forward.pod.kubernetes.io/relay-to"
+
+	// DefaultImage is the image relay pods run when none is configured. It must provide socat.
+	DefaultImage = "alpine/socat:1.7.4.4"
+	// DefaultMaxLifetime is how long relay pods run when they are not deleted, e.g., because the plugin was killed.
+	DefaultMaxLifetime = 12 * time.Hour
+
+	// containerName is the name of the relay pod's only container.
+	containerName = "relay"
+)
+
+// Options configures a relay pod.
+type Options struct {
+	// To is the address connections are relayed to, e.g., "db.xyz.rds.amazonaws.com:5432".
+	To string
+	// Image is the image of the relay container, DefaultImage when empty.
+	Image string
+	// Namespace is the namespace the pod is created in.
+	Namespace string
+	// ServiceAccount is the pod's service account, the namespace's default one when empty.
+	ServiceAccount string
+	// NodeSelector constrains the nodes the pod is scheduled on.
+	NodeSelector map[string]string
+	// Annotations are added to the pod, e.g., to declare the commands run over the relay.
+	Annotations map[string]string
+	// MaxLifetime is how long the pod runs before being stopped by Kubernetes, DefaultMaxLifetime when zero.
+	MaxLifetime time.Duration
+}
+
+// Port returns the port connections are relayed to, which is also the port the relay pod listens on.
+func (o Options) Port() (int, error) {
+	host, port, err := net.SplitHostPort(o.To)
+	if err != nil {
+		return 0, fmt.Errorf("invalid relay address %q: %w", o.To, err)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 || host == "" {
+		return 0, fmt.Errorf("invalid relay address %q, must be in host:port format", o.To)
+	}
+
+	return p, nil
+}
+
+// NewPod returns the relay pod configured by the options, listening on the target's port and forwarding connections
+// to it with socat.
+func NewPod(o Options) (*corev1.Pod, error) {
+	port, err := o.Port()
+	if err != nil {
+		return nil, err
+	}
+
+	image := o.Image
+	if image == "" {
+		image = DefaultImage
+	}
+
+	lifetime := o.MaxLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultMaxLifetime
+	}
+
+	deadline := int64(lifetime.Seconds())
+
+	annotations := map[string]string{}
+	for k, v := range o.Annotations {
+		annotations[k] = v
+	}
+
+	annotations[ToAnnotation] = o.To
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "exec-forward-relay-",
+			Namespace:    o.Namespace,
+			Labels: map[string]string{
+				Label:                          "true",
+				"app.kubernetes.io/managed-by": "kubectl-exec-forward",
+			},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			ServiceAccountName:    o.ServiceAccount,
+			NodeSelector:          o.NodeSelector,
+			Containers: []corev1.Container{
+				{
+					Name:  containerName,
+					Image: image,
+					Args: []string{
+						fmt.Sprintf("tcp-listen:%d,fork,reuseaddr", port),
+						fmt.Sprintf("tcp-connect:%s", o.To),
+					},
+					Ports: []corev1.ContainerPort{{Name: containerName, ContainerPort: int32(port)}},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(port)},
+						},
+						PeriodSeconds: 1,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// Client creates and deletes relay pods.
+type Client struct {
+	clientset kubernetes.Interface
+	now       func() time.Time
+}
+
+// New returns a client managing relay pods with the passed clientset.
+func New(clientset kubernetes.Interface) *Client {
+	return &Client{clientset: clientset, now: time.Now}
+}
+
+// Create creates the relay pod configured by the options.
+func (c *Client) Create(ctx context.Context, o Options) (*corev1.Pod, error) {
+	pod, err := NewPod(o)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err = c.clientset.CoreV1().Pods(o.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("creating relay pod: %w", err)
+	}
+
+	return pod, nil
+}
+
+// Delete deletes the relay pod right away. A pod that no longer exists is not an error.
+func (c *Client) Delete(ctx context.Context, pod *corev1.Pod) error {
+	var gracePeriod int64
+
+	err := c.clientset.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting relay pod %s: %w", pod.Name, err)
+	}
+
+	return nil
+}
+
+// GC deletes the relay pods of the namespace, all namespaces when empty, that have stopped or, when olderThan is
+// positive, were created longer than olderThan ago. It returns the deleted pods.
+func (c *Client) GC(ctx context.Context, namespace string, olderThan time.Duration) ([]corev1.Pod, error) {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: Label})
+	if err != nil {
+		return nil, fmt.Errorf("listing relay pods: %w", err)
+	}
+
+	deleted := []corev1.Pod{}
+
+	for _, pod := range pods.Items {
+		pod := pod
+
+		stopped := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+		expired := olderThan > 0 && c.now().Sub(pod.CreationTimestamp.Time) > olderThan
+
+		if !stopped && !expired {
+			continue
+		}
+
+		if err := c.Delete(ctx, &pod); err != nil {
+			return deleted, err
+		}
+
+		deleted = append(deleted, pod)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,152 @@
+package relay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewPod(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		options Options
+
+		args  []string
+		image string
+		error bool
+	}{
+		{
+			name:    "default image",
+			options: Options{To: "db.xyz.rds.amazonaws.com:5432", Namespace: "db"},
+			args:    []string{"tcp-listen:5432,fork,reuseaddr", "tcp-connect:db.xyz.rds.amazonaws.com:5432"},
+			image:   DefaultImage,
+		},
+		{
+			name:    "custom image",
+			options: Options{To: "10.0.0.1:6379", Image: "registry.local/socat"},
+			args:    []string{"tcp-listen:6379,fork,reuseaddr", "tcp-connect:10.0.0.1:6379"},
+			image:   "registry.local/socat",
+		},
+		{
+			name:    "missing port",
+			options: Options{To: "db.xyz.rds.amazonaws.com"},
+			error:   true,
+		},
+		{
+			name:    "invalid port",
+			options: Options{To: "db.xyz.rds.amazonaws.com:postgres"},
+			error:   true,
+		},
+		{
+			name:    "missing host",
+			options: Options{To: ":5432"},
+			error:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pod, err := NewPod(tc.options)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, pod.Spec.Containers, 1)
+
+			container := pod.Spec.Containers[0]
+
+			assert.Equal(t, tc.args, container.Args)
+			assert.Equal(t, tc.image, container.Image)
+			assert.Equal(t, "true", pod.Labels[Label])
+			assert.Equal(t, tc.options.To, pod.Annotations[ToAnnotation])
+			assert.Equal(t, int64(DefaultMaxLifetime.Seconds()), *pod.Spec.ActiveDeadlineSeconds)
+		})
+	}
+}
+
+func TestClientGC(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	newPod := func(name string, age time.Duration, phase corev1.PodPhase, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "db",
+				Labels:            labels,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	relayLabels := map[string]string{Label: "true"}
+
+	cases := []struct {
+		name string
+
+		olderThan time.Duration
+
+		deleted []string
+	}{
+		{
+			name:    "stopped relay pods",
+			deleted: []string{"relay-failed"},
+		},
+		{
+			name:      "stopped and old relay pods",
+			olderThan: time.Hour,
+			deleted:   []string{"relay-failed", "relay-old"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset(
+				newPod("relay-running", time.Minute, corev1.PodRunning, relayLabels),
+				newPod("relay-old", 2*time.Hour, corev1.PodRunning, relayLabels),
+				newPod("relay-failed", time.Minute, corev1.PodFailed, relayLabels),
+				newPod("db-0", 2*time.Hour, corev1.PodFailed, map[string]string{"app": "db"}),
+			)
+
+			client := New(clientset)
+			client.now = func() time.Time { return now }
+
+			deleted, err := client.GC(context.Background(), "", tc.olderThan)
+			require.NoError(t, err)
+
+			names := []string{}
+			for _, pod := range deleted {
+				names = append(names, pod.Name)
+			}
+
+			assert.ElementsMatch(t, tc.deleted, names)
+
+			pods, err := clientset.CoreV1().Pods("db").List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			assert.Len(t, pods.Items, 4-len(tc.deleted))
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package tracing exports OpenTelemetry traces of the forwarding lifecycle, from resolving the pod to running the
+// commands, to an OTLP collector or a file. Spans are only recorded once Setup has configured an exporter.
+package tracing
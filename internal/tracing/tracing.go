@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the name of the tracer spans are recorded with.
+const instrumentation = "github.com/takescoop/kubectl-exec-forward"
+
+// Options configures where traces are exported to. Traces are exported to each configured destination.
+type Options struct {
+	// Endpoint is the URL of an OTLP/HTTP collector, e.g., http://localhost:4318.
+	Endpoint string
+	// File is the path of a file spans are written to as JSON.
+	File string
+	// Version is the version of the plugin, recorded on every span.
+	Version string
+}
+
+// Enabled returns whether the options configure any destination.
+func (o Options) Enabled() bool {
+	return o.Endpoint != "" || o.File != ""
+}
+
+// Setup registers a tracer provider exporting spans as configured. The returned function flushes pending spans and
+// shuts the exporters down. When no destination is configured, spans are not recorded.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	if !options.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName("kubectl-exec-forward"),
+			semconv.ServiceVersion(options.Version),
+		)),
+	}
+
+	closers := []func() error{}
+
+	if options.Endpoint != "" {
+		exporter, err := newOTLPExporter(ctx, options.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		providerOptions = append(providerOptions, sdktrace.WithBatcher(exporter))
+	}
+
+	if options.File != "" {
+		f, err := os.Create(options.File)
+		if err != nil {
+			return nil, err
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+
+			return nil, err
+		}
+
+		closers = append(closers, f.Close)
+		providerOptions = append(providerOptions, sdktrace.WithSyncer(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(providerOptions...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+
+		for _, c := range closers {
+			if closeErr := c(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+
+		return err
+	}, nil
+}
+
+// newOTLPExporter returns an exporter sending spans to the OTLP/HTTP collector at endpoint.
+func newOTLPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("trace endpoint %q must be a URL, e.g., http://localhost:4318", endpoint)
+	}
+
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(path.Join("/", u.Path, "v1/traces")),
+	}
+
+	switch u.Scheme {
+	case "http":
+		options = append(options, otlptracehttp.WithInsecure())
+	case "https":
+	default:
+		return nil, fmt.Errorf("trace endpoint %q must use the http or https scheme", endpoint)
+	}
+
+	return otlptracehttp.New(ctx, options...)
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends the span, recording err as its status when not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
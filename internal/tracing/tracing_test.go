@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TestSetup is not run in parallel, since Setup registers the global tracer provider.
+func TestSetup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.json")
+
+	shutdown, err := Setup(context.Background(), Options{File: path, Version: "1.0.0"})
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "exec-forward")
+	_, child := Start(ctx, "resolve pod", attribute.String("exec_forward.resource", "svc/db"))
+	End(child, errors.New("pod not found"))
+	End(parent, nil)
+
+	require.NoError(t, shutdown(context.Background()))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Spans are written as one JSON object each, children first since they end first.
+	results := []gjson.Result{}
+
+	gjson.ForEachLine(string(b), func(line gjson.Result) bool {
+		results = append(results, line)
+
+		return true
+	})
+
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "resolve pod", results[0].Get("Name").String())
+	assert.Equal(t, "Error", results[0].Get("Status.Code").String())
+	assert.Equal(t, "svc/db", results[0].Get(`Attributes.#(Key=="exec_forward.resource").Value.Value`).String())
+	assert.Equal(t, results[1].Get("SpanContext.SpanID").String(), results[0].Get("Parent.SpanID").String())
+	assert.Equal(t, "exec-forward", results[1].Get("Name").String())
+	assert.Equal(t, "1.0.0", results[1].Get(`Resource.#(Key=="service.version").Value.Value`).String())
+}
+
+func TestSetupDisabled(t *testing.T) {
+	t.Parallel()
+
+	shutdown, err := Setup(context.Background(), Options{})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestNewOTLPExporter(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+
+		endpoint string
+
+		error bool
+	}{
+		{name: "http endpoint", endpoint: "http://localhost:4318"},
+		{name: "https endpoint with a path", endpoint: "https://collector.example.com/otlp"},
+		{name: "missing scheme", endpoint: "localhost:4318", error: true},
+		{name: "unsupported scheme", endpoint: "grpc://localhost:4317", error: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			exporter, err := newOTLPExporter(context.Background(), tc.endpoint)
+
+			if tc.error {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NoError(t, exporter.Shutdown(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package trust
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// ErrUntrusted is returned when the user declines to run commands that are not trusted by the policy.
+var ErrUntrusted = errors.New("refusing to run untrusted commands, confirm them interactively or pass --yes")
+
+// Step is a command run at a given lifecycle stage.
+type Step struct {
+	Stage   string
+	Command command.Command
+}
+
+// Request describes the commands read from a pod's annotations that are awaiting a trust decision.
+type Request struct {
+	Namespace string
+	Pod       string
+	// Canonical is the canonical form of the annotations the commands were read from.
+	Canonical []byte
+	// Signature is the base64 encoded signature over Canonical, if any.
+	Signature string
+	Steps     []Step
+	// Data renders the commands shown to the user, with sensitive values masked.
+	Data command.TemplateData
+}
+
+// Check returns nil when the request's commands may be run. Commands from annotations with a valid signature are
+// trusted, while invalid signatures, and missing signatures when signing is required, are refused. Other commands not
+// trusted by the policy are shown to the user who is asked to confirm them; once confirmed, the annotation set is saved
+// to the policy and is not prompted for again until it changes. When assumeYes is set, untrusted commands are run
+// without confirmation.
+func (p *Policy) Check(r Request, assumeYes bool, streams genericclioptions.IOStreams) error {
+	if len(r.Steps) == 0 {
+		return nil
+	}
+
+	signed, err := p.verifySignature(r)
+	if err != nil {
+		return err
+	}
+
+	if signed || assumeYes || p.trusts(r) {
+		return nil
+	}
+
+	fmt.Fprintf(streams.ErrOut, "The following commands from pod %s/%s have not been trusted:\n", r.Namespace, r.Pod)
+
+	for _, s := range r.Steps {
+		fmt.Fprintf(streams.ErrOut, "  [%s] %s\n", s.Stage, s.Command.Preview(r.Data))
+	}
+
+	fmt.Fprint(streams.ErrOut, "Run these commands? [y/N]: ")
+
+	if !confirmed(streams) {
+		fmt.Fprintln(streams.ErrOut)
+
+		return ErrUntrusted
+	}
+
+	p.trust(r)
+
+	if err := p.Save(); err != nil {
+		fmt.Fprintf(streams.ErrOut, "Unable to save trust policy: %v\n", err)
+	}
+
+	return nil
+}
+
+// confirmed reads a yes or no answer from the input stream, defaulting to no.
+func confirmed(streams genericclioptions.IOStreams) bool {
+	if streams.In == nil {
+		return false
+	}
+
+	answer, err := readLine(streams.In)
+	if err != nil && answer == "" {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// readLine reads a line from in, one byte at a time so that input following the line is left to later readers, e.g.,
+// the main command's input when it is piped to the plugin.
+func readLine(in io.Reader) (string, error) {
+	line := []byte{}
+	b := make([]byte, 1)
+
+	for {
+		n, err := in.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+
+			line = append(line, b[0])
+		}
+
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package trust
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pborman/ansi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	t.Parallel()
+
+	request := Request{
+		Namespace: "db",
+		Pod:       "db-0",
+		Canonical: []byte(`{"foo":"bar"}`),
+		Steps: []Step{
+			{Stage: "pre-connect", Command: command.Command{DisplayName: "token", Command: []string{"aws", "{{.Args.host}}", "{{sensitive .Args.password}}"}}},
+			{Stage: "command", Command: command.Command{Command: []string{"psql", "{{.Outputs.token}}"}}},
+		},
+		Data: command.TemplateData{Args: command.Args{"host": "db.example.com", "password": "secret"}},
+	}
+
+	prompt := "The following commands from pod db/db-0 have not been trusted:\n" +
+		"  [pre-connect] token: aws db.example.com ********\n" +
+		"  [command] psql {{.Outputs.token}}\n" +
+		"Run these commands? [y/N]: "
+
+	cases := []struct {
+		name      string
+		policy    Policy
+		request   Request
+		assumeYes bool
+		stdin     string
+
+		stderr    string
+		remaining string
+		trusted   bool
+		error     error
+	}{
+		{
+			name:    "no commands",
+			request: Request{Namespace: "db", Pod: "db-0"},
+		},
+		{
+			name:    "allowed by policy",
+			policy:  Policy{AllowedExecutables: []string{"aws", "psql"}},
+			request: request,
+		},
+		{
+			name:      "assume yes",
+			request:   request,
+			assumeYes: true,
+		},
+		{
+			name:    "confirmed",
+			request: request,
+			stdin:   "y\n",
+			stderr:  prompt,
+			trusted: true,
+		},
+		{
+			name:      "confirmed leaving the main command's input",
+			request:   request,
+			stdin:     "y\nSELECT 1;\n",
+			stderr:    prompt,
+			remaining: "SELECT 1;\n",
+			trusted:   true,
+		},
+		{
+			name:    "declined",
+			request: request,
+			stdin:   "n\n",
+			stderr:  prompt + "\n",
+			error:   ErrUntrusted,
+		},
+		{
+			name:    "no answer",
+			request: request,
+			stderr:  prompt + "\n",
+			error:   ErrUntrusted,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "trust.yaml")
+			tc.policy.path = path
+
+			streams, stdin, _, stderr := genericclioptions.NewTestIOStreams()
+			stdin.WriteString(tc.stdin)
+
+			err := tc.policy.Check(tc.request, tc.assumeYes, streams)
+
+			if tc.error != nil {
+				assert.ErrorIs(t, err, tc.error)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			plainStderr, err := ansi.Strip(stderr.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, tc.stderr, string(plainStderr))
+			assert.Equal(t, tc.remaining, stdin.String())
+
+			saved, err := Load(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.trusted, saved.trusts(Request{Canonical: tc.request.Canonical}))
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package trust implements a local trust policy that decides whether commands read from pod annotations may be run.
+// Commands are run without confirmation when the policy allows them, otherwise the user is shown the commands and
+// asked to confirm before any of them is executed.
+package trust
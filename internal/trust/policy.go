@@ -0,0 +1,140 @@
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Policy lists the executables, namespaces and annotation sets that are trusted to run without confirmation.
+type Policy struct {
+	// AllowedExecutables are the programs that may be run without confirmation. A value of "*" allows any program.
+	AllowedExecutables []string `json:"allowedExecutables,omitempty"`
+	// AllowedNamespaces restricts AllowedExecutables to pods in the listed namespaces. When empty, all namespaces are allowed.
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+	// TrustedHashes are the hashes of annotation sets that were previously confirmed.
+	TrustedHashes []string `json:"trustedHashes,omitempty"`
+	// RequireSigned refuses to run commands from annotations without a valid signature.
+	RequireSigned bool `json:"requireSigned,omitempty"`
+	// PublicKeys are paths to PEM encoded public keys used to verify annotation signatures.
+	PublicKeys []string `json:"publicKeys,omitempty"`
+
+	path      string
+	overrides overrides
+}
+
+// overrides are settings applied for a single invocation, which are never saved to the policy file.
+type overrides struct {
+	requireSigned bool
+	publicKeys    []string
+}
+
+// DefaultPath returns the default location of the trust policy file.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "kubectl-exec-forward", "trust.yaml")
+}
+
+// Load reads the trust policy stored at path. A missing file results in an empty policy that is created when saved.
+func Load(path string) (*Policy, error) {
+	policy := &Policy{path: path}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return policy, nil
+		}
+
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(b, policy); err != nil {
+		return nil, fmt.Errorf("parsing trust policy %s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+// Override requires signed annotations and adds public keys for the current invocation only, without changing the
+// policy file.
+func (p *Policy) Override(requireSigned bool, publicKeys []string) {
+	p.overrides.requireSigned = p.overrides.requireSigned || requireSigned
+	p.overrides.publicKeys = append(p.overrides.publicKeys, publicKeys...)
+}
+
+// Save writes the policy to the path it was loaded from, readable only by the current user.
+func (p *Policy) Save() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.path, b, 0o600)
+}
+
+// Hash returns the hash identifying a canonical annotation set.
+func Hash(canonical []byte) string {
+	sum := sha256.Sum256(canonical)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// trusts returns whether the request is allowed without confirmation.
+func (p *Policy) trusts(r Request) bool {
+	if contains(p.TrustedHashes, Hash(r.Canonical)) {
+		return true
+	}
+
+	if len(p.AllowedNamespaces) > 0 && !contains(p.AllowedNamespaces, r.Namespace) {
+		return false
+	}
+
+	if len(p.AllowedExecutables) == 0 {
+		return false
+	}
+
+	if contains(p.AllowedExecutables, "*") {
+		return true
+	}
+
+	for _, s := range r.Steps {
+		if !contains(p.AllowedExecutables, s.Command.Name()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// trust records the request's annotation set as trusted.
+func (p *Policy) trust(r Request) {
+	h := Hash(r.Canonical)
+
+	if !contains(p.TrustedHashes, h) {
+		p.TrustedHashes = append(p.TrustedHashes, h)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
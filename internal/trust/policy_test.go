@@ -0,0 +1,125 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trust.yaml")
+
+		policy, err := Load(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, &Policy{path: path}, policy)
+	})
+
+	t.Run("policy file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trust.yaml")
+
+		require.NoError(t, os.WriteFile(path, []byte("allowedExecutables: [psql]\nallowedNamespaces: [db]\n"), 0o600))
+
+		policy, err := Load(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"psql"}, policy.AllowedExecutables)
+		assert.Equal(t, []string{"db"}, policy.AllowedNamespaces)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trust.yaml")
+
+		require.NoError(t, os.WriteFile(path, []byte("allowedExecutables: psql: ["), 0o600))
+
+		_, err := Load(path)
+		assert.Error(t, err)
+	})
+}
+
+func TestPolicySave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "trust.yaml")
+
+	policy, err := Load(path)
+	require.NoError(t, err)
+
+	policy.TrustedHashes = []string{"sha256:abc"}
+	require.NoError(t, policy.Save())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, policy, loaded)
+}
+
+func TestPolicyTrusts(t *testing.T) {
+	t.Parallel()
+
+	request := Request{
+		Namespace: "db",
+		Canonical: []byte(`{}`),
+		Steps: []Step{
+			{Stage: "pre-connect", Command: command.Command{Command: []string{"aws", "rds"}}},
+			{Stage: "command", Command: command.Command{Command: []string{"psql"}}},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		policy   Policy
+		expected bool
+	}{
+		{
+			name:     "empty policy",
+			expected: false,
+		},
+		{
+			name:     "trusted hash",
+			policy:   Policy{TrustedHashes: []string{Hash([]byte(`{}`))}},
+			expected: true,
+		},
+		{
+			name:     "all executables allowed",
+			policy:   Policy{AllowedExecutables: []string{"aws", "psql"}},
+			expected: true,
+		},
+		{
+			name:     "some executables allowed",
+			policy:   Policy{AllowedExecutables: []string{"psql"}},
+			expected: false,
+		},
+		{
+			name:     "any executable",
+			policy:   Policy{AllowedExecutables: []string{"*"}},
+			expected: true,
+		},
+		{
+			name:     "allowed namespace",
+			policy:   Policy{AllowedExecutables: []string{"*"}, AllowedNamespaces: []string{"db"}},
+			expected: true,
+		},
+		{
+			name:     "other namespace",
+			policy:   Policy{AllowedExecutables: []string{"*"}, AllowedNamespaces: []string{"web"}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.policy.trusts(request))
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package trust
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrUnsigned is returned when signed annotations are required but the annotations carry no signature.
+	ErrUnsigned = errors.New("annotations are not signed and signed annotations are required")
+	// ErrInvalidSignature is returned when the annotations' signature does not match any configured public key.
+	ErrInvalidSignature = errors.New("annotation signature does not match any configured public key, the annotations may have been tampered with")
+)
+
+// LoadPublicKey reads a PEM encoded PKIX public key from path. Ed25519 and ECDSA keys, as generated by cosign, are
+// supported.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in public key %s", path)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key %s: %w", path, err)
+	}
+
+	switch key.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T in %s", key, path)
+	}
+}
+
+// Verify checks that the base64 encoded signature was made over the canonical annotations by one of the passed keys.
+// Ed25519 signatures are made over the canonical form itself, ECDSA signatures over its SHA-256 digest.
+func Verify(canonical []byte, signature string, keys []crypto.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	digest := sha256.Sum256(canonical)
+
+	for _, key := range keys {
+		switch k := key.(type) {
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, canonical, sig) {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, digest[:], sig) {
+				return nil
+			}
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// publicKeys loads every public key configured in the policy file and through overrides. Relative paths in the
+// policy file are resolved from the policy file's directory.
+func (p *Policy) publicKeys() ([]crypto.PublicKey, error) {
+	paths := []string{}
+
+	for _, path := range p.PublicKeys {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(p.path), path)
+		}
+
+		paths = append(paths, path)
+	}
+
+	paths = append(paths, p.overrides.publicKeys...)
+
+	keys := []crypto.PublicKey{}
+
+	for _, path := range paths {
+		key, err := LoadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// verifySignature checks the request's signature against the configured public keys. It returns whether the
+// annotations carry a valid signature, or an error if a signature is required but missing, or present but invalid.
+func (p *Policy) verifySignature(r Request) (bool, error) {
+	requireSigned := p.RequireSigned || p.overrides.requireSigned
+
+	if r.Signature == "" {
+		if requireSigned {
+			return false, ErrUnsigned
+		}
+
+		return false, nil
+	}
+
+	keys, err := p.publicKeys()
+	if err != nil {
+		return false, err
+	}
+
+	if len(keys) == 0 {
+		if requireSigned {
+			return false, errors.New("signed annotations are required but no public keys are configured")
+		}
+
+		return false, nil
+	}
+
+	if err := Verify(r.Canonical, r.Signature, keys); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,238 @@
+package trust
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func writePublicKey(t *testing.T, dir string, name string, key crypto.PublicKey) string {
+	t.Helper()
+
+	b, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), 0o600))
+
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	canonical := []byte(`{"exec-forward.pod.kubernetes.io/command":"{\"command\":[\"psql\"]}"}`)
+	digest := sha256.Sum256(canonical)
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ecSignature, err := ecdsa.SignASN1(rand.Reader, ecPrivate, digest[:])
+	require.NoError(t, err)
+
+	edSignature := base64.StdEncoding.EncodeToString(ed25519.Sign(edPrivate, canonical))
+
+	cases := []struct {
+		name      string
+		canonical []byte
+		signature string
+		keys      []crypto.PublicKey
+		error     error
+	}{
+		{
+			name:      "ed25519",
+			canonical: canonical,
+			signature: edSignature,
+			keys:      []crypto.PublicKey{otherPublic, edPublic},
+		},
+		{
+			name:      "ecdsa",
+			canonical: canonical,
+			signature: base64.StdEncoding.EncodeToString(ecSignature),
+			keys:      []crypto.PublicKey{&ecPrivate.PublicKey},
+		},
+		{
+			name:      "tampered",
+			canonical: []byte(`{"exec-forward.pod.kubernetes.io/command":"{\"command\":[\"curl\"]}"}`),
+			signature: edSignature,
+			keys:      []crypto.PublicKey{edPublic},
+			error:     ErrInvalidSignature,
+		},
+		{
+			name:      "unknown key",
+			canonical: canonical,
+			signature: edSignature,
+			keys:      []crypto.PublicKey{otherPublic},
+			error:     ErrInvalidSignature,
+		},
+		{
+			name:      "malformed signature",
+			canonical: canonical,
+			signature: "not base64!",
+			keys:      []crypto.PublicKey{edPublic},
+			error:     ErrInvalidSignature,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Verify(tc.canonical, tc.signature, tc.keys)
+
+			if tc.error != nil {
+				assert.ErrorIs(t, err, tc.error)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	dir := t.TempDir()
+
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	key, err := LoadPublicKey(writePublicKey(t, dir, "ed25519.pub", edPublic))
+	require.NoError(t, err)
+	assert.Equal(t, edPublic, key)
+
+	key, err = LoadPublicKey(writePublicKey(t, dir, "cosign.pub", &ecPrivate.PublicKey))
+	require.NoError(t, err)
+	assert.Equal(t, &ecPrivate.PublicKey, key)
+
+	invalid := filepath.Join(dir, "invalid.pub")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a key"), 0o600))
+
+	_, err = LoadPublicKey(invalid)
+	assert.Error(t, err)
+}
+
+func TestPolicyCheckSignature(t *testing.T) {
+	t.Parallel()
+
+	canonical := []byte(`{"exec-forward.pod.kubernetes.io/command":"{\"command\":[\"psql\"]}"}`)
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, canonical))
+	steps := []Step{{Stage: "command", Command: command.Command{Command: []string{"psql"}}}}
+
+	cases := []struct {
+		name          string
+		policy        Policy
+		requireSigned bool
+		keys          bool
+		signature     string
+		canonical     []byte
+		assumeYes     bool
+		error         error
+	}{
+		{
+			name:      "valid signature is trusted",
+			policy:    Policy{PublicKeys: []string{"key.pub"}},
+			signature: signature,
+			canonical: canonical,
+		},
+		{
+			name:          "valid signature from an override key",
+			requireSigned: true,
+			keys:          true,
+			signature:     signature,
+			canonical:     canonical,
+		},
+		{
+			name:      "tampered annotations are refused",
+			policy:    Policy{PublicKeys: []string{"key.pub"}},
+			signature: signature,
+			canonical: []byte(`{}`),
+			assumeYes: true,
+			error:     ErrInvalidSignature,
+		},
+		{
+			name:      "unsigned annotations are refused when required by the policy",
+			policy:    Policy{RequireSigned: true, PublicKeys: []string{"key.pub"}},
+			canonical: canonical,
+			assumeYes: true,
+			error:     ErrUnsigned,
+		},
+		{
+			name:          "unsigned annotations are refused when required by an override",
+			requireSigned: true,
+			canonical:     canonical,
+			assumeYes:     true,
+			error:         ErrUnsigned,
+		},
+		{
+			name:      "unsigned annotations fall back to confirmation",
+			canonical: canonical,
+			error:     ErrUntrusted,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			keyPath := writePublicKey(t, dir, "key.pub", public)
+
+			tc.policy.path = filepath.Join(dir, "trust.yaml")
+
+			keys := []string{}
+			if tc.keys {
+				keys = append(keys, keyPath)
+			}
+
+			tc.policy.Override(tc.requireSigned, keys)
+
+			err := tc.policy.Check(Request{
+				Namespace: "db",
+				Pod:       "db-0",
+				Canonical: tc.canonical,
+				Signature: tc.signature,
+				Steps:     steps,
+			}, tc.assumeYes, genericclioptions.NewTestIOStreamsDiscard())
+
+			if tc.error != nil {
+				assert.ErrorIs(t, err, tc.error)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+// Package execforward embeds kubectl-exec-forward in other Go programs. Start resolves a Kubernetes resource to a pod,
+// runs the commands found in the pod's annotations and forwards a local port to it, like the plugin does, returning a
+// Session that can be waited on or closed.
+//
+//	session, err := execforward.Start(ctx, "svc/db", "5432", execforward.WithRESTConfig(config))
+//	if err != nil {
+//		return err
+//	}
+//
+//	defer session.Close()
+//
+// Like the plugin, annotation commands are checked against the trust policy stored in the user's configuration
+// directory, or the one passed to WithTrustPolicy. Commands that are not trusted are shown on the session's streams and
+// only run once confirmed there, so programs without an interactive input must either trust the commands ahead of time
+// or explicitly opt out of the confirmation with WithAssumeYes.
+package execforward
@@ -0,0 +1,23 @@
+package execforward
+
+import (
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+)
+
+// FakeRunner is a Runner that records processes instead of running them and replies with scripted results, to unit
+// test annotation commands without running any program.
+type FakeRunner = command.FakeRunner
+
+// FakeResult is the scripted result of a process run by a FakeRunner.
+type FakeResult = command.FakeResult
+
+// FakeRun is a process recorded by a FakeRunner.
+type FakeRun = command.FakeRun
+
+// FakeExitError is returned by a FakeRunner for processes scripted with a non-zero exit code.
+type FakeExitError = command.FakeExitError
+
+// NewFakeRunner returns a runner without scripted results.
+func NewFakeRunner() *FakeRunner {
+	return command.NewFakeRunner()
+}
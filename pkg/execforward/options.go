@@ -0,0 +1,181 @@
+package execforward
+
+import (
+	"io"
+	"time"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/execforward"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Option configures a session.
+type Option func(*options)
+
+// options stores the configuration assembled from the options passed to Start.
+type options struct {
+	restConfig *rest.Config
+	clientset  kubernetes.Interface
+	namespace  string
+	in         io.Reader
+	out        io.Writer
+	errOut     io.Writer
+	podTimeout time.Duration
+	transport  Transport
+	version    string
+	args       map[string]string
+	trustPath  string
+	cacheDir   string
+	config     execforward.Config
+}
+
+// WithRESTConfig connects to the Kubernetes API with the passed rest configuration. By default, the configuration is
+// loaded from kubeconfig files like kubectl does.
+func WithRESTConfig(config *rest.Config) Option {
+	return func(o *options) {
+		o.restConfig = config
+	}
+}
+
+// WithClientset sends Kubernetes API requests, such as access reviews, with the passed clientset. Resources are still
+// resolved and port-forwarded with the rest configuration.
+func WithClientset(clientset kubernetes.Interface) Option {
+	return func(o *options) {
+		o.clientset = clientset
+	}
+}
+
+// WithNamespace resolves the resource in the passed namespace rather than the configured one.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithIO attaches the session to the passed streams rather than the process' standard streams. The main command is
+// attached to them, and progress messages are written to errOut.
+func WithIO(in io.Reader, out io.Writer, errOut io.Writer) Option {
+	return func(o *options) {
+		o.in = in
+		o.out = out
+		o.errOut = errOut
+	}
+}
+
+// WithEvents notifies the passed events of the progress of the session.
+func WithEvents(events Events) Option {
+	return func(o *options) {
+		o.config.Events = events
+	}
+}
+
+// WithRunner runs the processes of commands with the passed runner rather than on the local machine.
+func WithRunner(runner Runner) Option {
+	return func(o *options) {
+		o.config.Runner = runner
+	}
+}
+
+// WithArgs passes arguments to the commands, overriding those found in the annotations.
+func WithArgs(args map[string]string) Option {
+	return func(o *options) {
+		o.args = args
+	}
+}
+
+// WithCommand replaces the program of the main command, e.g., to run a different client.
+func WithCommand(command ...string) Option {
+	return func(o *options) {
+		o.config.Command = command
+	}
+}
+
+// WithContainer resolves named ports and container-scoped annotations against the passed container.
+func WithContainer(container string) Option {
+	return func(o *options) {
+		o.config.Container = container
+	}
+}
+
+// WithPersist keeps the connection open after the main command has finished, until the session is closed.
+func WithPersist() Option {
+	return func(o *options) {
+		o.config.Persist = true
+	}
+}
+
+// WithVerbose writes the outputs of commands to the session's streams.
+func WithVerbose() Option {
+	return func(o *options) {
+		o.config.Verbose = true
+	}
+}
+
+// WithGracePeriod sets how long commands are given to exit on shutdown before being killed, also bounding teardown
+// commands and the rest of the shutdown. Defaults to 10 seconds.
+func WithGracePeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.config.GracePeriod = d
+	}
+}
+
+// WithIdleTimeout closes the session once no traffic has gone through the forwarded ports for d. The idle timeout set
+// by the pod's annotations applies when d is zero or longer.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.config.IdleTimeout = d
+	}
+}
+
+// WithMaxDuration closes the session once it has been connected for d. The maximum duration set by the pod's
+// annotations applies when d is zero or longer.
+func WithMaxDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.config.MaxDuration = d
+	}
+}
+
+// WithPodTimeout sets how long to wait for an attachable pod to become available. Defaults to 500 milliseconds.
+func WithPodTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.podTimeout = d
+	}
+}
+
+// WithTransport sets the port-forwarding transport. Defaults to TransportSPDY.
+func WithTransport(transport Transport) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithTrustPolicy checks annotation commands against the trust policy file at path rather than the plugin's, which is
+// stored in the user's configuration directory.
+func WithTrustPolicy(path string) Option {
+	return func(o *options) {
+		o.trustPath = path
+	}
+}
+
+// WithAssumeYes runs annotation commands not trusted by the trust policy without asking for confirmation, like the
+// plugin's --yes flag. Invalid signatures, and missing ones when signing is required, are still refused.
+func WithAssumeYes() Option {
+	return func(o *options) {
+		o.config.AssumeYes = true
+	}
+}
+
+// WithCacheDir caches the outputs of commands with cache options in the passed directory. By default, outputs are not
+// cached.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithVersion sets the version reported in the user agent of Kubernetes API requests, kubectl-exec-forward/<version>.
+func WithVersion(version string) Option {
+	return func(o *options) {
+		o.version = version
+	}
+}
@@ -0,0 +1,146 @@
+package execforward
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/takescoop/kubectl-exec-forward/internal/command"
+	"github.com/takescoop/kubectl-exec-forward/internal/execforward"
+	"github.com/takescoop/kubectl-exec-forward/internal/forwarder"
+	"github.com/takescoop/kubectl-exec-forward/internal/trust"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Port is a forwarded port, with the local port listened on and the remote port of the pod.
+type Port = forwarder.Connection
+
+// Events receives notifications about the progress of a session.
+type Events = execforward.Events
+
+// NopEvents ignores every notification. It can be embedded by implementations only interested in some events.
+type NopEvents = execforward.NopEvents
+
+// Runner runs the processes of commands.
+type Runner = command.Runner
+
+// Process is a single run of a command, with its arguments rendered.
+type Process = command.Process
+
+// Transport is the protocol port-forwarding connections are made with.
+type Transport = forwarder.Transport
+
+// Port-forwarding transports accepted by WithTransport.
+const (
+	TransportSPDY      = forwarder.TransportSPDY
+	TransportWebSocket = forwarder.TransportWebSocket
+	TransportAuto      = forwarder.TransportAuto
+)
+
+// Stages of the forwarding lifecycle reported to Events, in execution order.
+const (
+	StagePreConnect  = execforward.StagePreConnect
+	StagePostConnect = execforward.StagePostConnect
+	StageCommand     = execforward.StageCommand
+	StageTeardown    = execforward.StageTeardown
+)
+
+// Session is an open forwarding connection along with the commands run over it.
+type Session struct {
+	session *execforward.Session
+}
+
+// Start runs the pre-connect commands of the resource's pod and opens a forwarding connection to it, where port is a
+// port mapping as passed to the plugin, e.g., "8080:http". It returns once the connection is established, while the
+// post-connect commands and the main command run in the background. The session is shut down once ctx is done.
+//
+// Returned errors, as well as those returned by Wait, map to the plugin's exit codes through ExitCode.
+func Start(ctx context.Context, resource string, port string, opts ...Option) (*Session, error) {
+	o := &options{
+		in:         os.Stdin,
+		out:        os.Stdout,
+		errOut:     os.Stderr,
+		podTimeout: 500 * time.Millisecond,
+		version:    "embedded",
+		args:       map[string]string{},
+		trustPath:  trust.DefaultPath(),
+		config: execforward.Config{
+			GracePeriod: 10 * time.Second,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	streams := genericclioptions.IOStreams{In: o.in, Out: o.out, ErrOut: o.errOut}
+	config := o.config
+
+	if o.trustPath != "" {
+		policy, err := trust.Load(o.trustPath)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Trust = policy
+	}
+
+	if o.cacheDir != "" {
+		config.Cache = command.NewFileCache(o.cacheDir)
+	}
+
+	client := forwarder.NewClient(o.podTimeout, streams)
+	client.Transport = o.transport
+
+	if err := client.InitWithClientset(o.getter(), o.clientset, o.version); err != nil {
+		return nil, err
+	}
+
+	session, err := execforward.Start(ctx, client, &config, o.args, resource, port, streams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{session: session}, nil
+}
+
+// getter returns the getter used to resolve resources and port-forward to pods.
+func (o *options) getter() genericclioptions.RESTClientGetter {
+	if o.restConfig != nil {
+		return forwarder.NewRESTConfigGetter(o.restConfig, o.namespace)
+	}
+
+	flags := genericclioptions.NewConfigFlags(false)
+
+	if o.namespace != "" {
+		flags.Namespace = &o.namespace
+	}
+
+	return flags
+}
+
+// Ports returns the forwarded ports.
+func (s *Session) Ports() []Port {
+	return s.session.Ports()
+}
+
+// Wait waits for the session to end, e.g., once the main command has finished, and returns the error that ended it.
+func (s *Session) Wait() error {
+	return s.session.Wait()
+}
+
+// Close shuts the session down, running its teardown commands, and waits for it to end.
+func (s *Session) Close() error {
+	return s.session.Close()
+}
+
+// ExitCode returns the exit code the plugin would exit with for an error returned by Start or Wait.
+func ExitCode(err error) int {
+	return execforward.ExitCode(err)
+}
+
+// PluginFailure returns whether an error returned by Start or Wait is a failure of the plugin itself rather than of
+// the main command, whose exit code may be the same as one of the plugin's.
+func PluginFailure(err error) bool {
+	return execforward.PluginFailure(err)
+}
@@ -0,0 +1,146 @@
+package execforward
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/kubectl-exec-forward/internal/annotation"
+	"github.com/takescoop/kubectl-exec-forward/internal/execforward"
+	"github.com/takescoop/kubectl-exec-forward/internal/kubetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	events := NopEvents{}
+
+	o := &options{}
+
+	for _, opt := range []Option{
+		WithNamespace("db"),
+		WithIO(nil, out, out),
+		WithEvents(events),
+		WithArgs(map[string]string{"username": "foo"}),
+		WithCommand("pgcli"),
+		WithContainer("postgres"),
+		WithPersist(),
+		WithVerbose(),
+		WithGracePeriod(time.Second),
+		WithPodTimeout(time.Minute),
+		WithTrustPolicy("trust.yaml"),
+		WithAssumeYes(),
+	} {
+		opt(o)
+	}
+
+	assert.Equal(t, "db", o.namespace)
+	assert.Equal(t, out, o.out)
+	assert.Equal(t, out, o.errOut)
+	assert.Equal(t, map[string]string{"username": "foo"}, o.args)
+	assert.Equal(t, time.Minute, o.podTimeout)
+	assert.Equal(t, "trust.yaml", o.trustPath)
+	assert.Equal(t, execforward.Config{
+		Command:     []string{"pgcli"},
+		Container:   "postgres",
+		Persist:     true,
+		Verbose:     true,
+		GracePeriod: time.Second,
+		AssumeYes:   true,
+		Events:      events,
+	}, o.config)
+}
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail to resolve the resource", func(t *testing.T) {
+		t.Parallel()
+
+		out := &bytes.Buffer{}
+
+		_, err := Start(context.Background(), "svc/db", "5432",
+			WithRESTConfig(&rest.Config{Host: "http://127.0.0.1:1"}),
+			WithIO(nil, out, out),
+		)
+
+		require.Error(t, err)
+		assert.Equal(t, execforward.ExitCodeConfig, ExitCode(err))
+	})
+
+	t.Run("forward to a pod and run its commands", func(t *testing.T) {
+		t.Parallel()
+
+		server := dbServer(t)
+
+		out := &bytes.Buffer{}
+		runner := NewFakeRunner()
+
+		session, err := Start(context.Background(), "pod/db-0", "0:5432",
+			WithRESTConfig(server.RESTConfig()),
+			WithNamespace("db"),
+			WithIO(&bytes.Buffer{}, out, out),
+			WithRunner(runner),
+			WithTrustPolicy(filepath.Join(t.TempDir(), "trust.yaml")),
+			WithAssumeYes(),
+		)
+		require.NoError(t, err)
+
+		port := session.Ports()[0].Local
+
+		assert.NoError(t, session.Wait())
+		assert.Equal(t, []FakeRun{{Argv: []string{"psql", "--port", strconv.Itoa(port)}, Interactive: true}}, runner.Runs())
+	})
+
+	t.Run("refuse untrusted commands unless confirmed", func(t *testing.T) {
+		t.Parallel()
+
+		server := dbServer(t)
+
+		out := &bytes.Buffer{}
+		runner := NewFakeRunner()
+
+		_, err := Start(context.Background(), "pod/db-0", "0:5432",
+			WithRESTConfig(server.RESTConfig()),
+			WithNamespace("db"),
+			WithIO(strings.NewReader("n\n"), out, out),
+			WithRunner(runner),
+			WithTrustPolicy(filepath.Join(t.TempDir(), "trust.yaml")),
+		)
+		require.Error(t, err)
+
+		assert.Equal(t, execforward.ExitCodeDenied, ExitCode(err))
+		assert.Contains(t, out.String(), "have not been trusted")
+		assert.Empty(t, runner.Runs())
+	})
+}
+
+// dbServer returns an API server with a running database pod whose main command is psql.
+func dbServer(t *testing.T) *kubetest.Server {
+	t.Helper()
+
+	server := kubetest.NewServer(t)
+	server.Add(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "db-0",
+			Namespace:   "db",
+			Annotations: map[string]string{annotation.Command: `{"command": ["psql", "--port", "{{.LocalPort}}"]}`},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "postgres", Ports: []corev1.ContainerPort{{ContainerPort: 5432}}},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	return server
+}